@@ -0,0 +1,455 @@
+package hd44780
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// animationStopTimeout is how long Shutdown waits for running animations
+// to return.
+const animationStopTimeout = time.Second
+
+// startAnimation registers a running animation, which has to call the
+// returned done function when it returns. The returned channel is closed
+// when Shutdown stops all animations. Animations started after that
+// belong to a new generation with its own WaitGroup, so a stopAnimations
+// still waiting for the old one never sees them added.
+func (lcd *Lcd) startAnimation() (shutdown <-chan struct{}, done func()) {
+	lcd.animationMutex.Lock()
+	defer lcd.animationMutex.Unlock()
+	if lcd.animationDone == nil {
+		lcd.animationDone = make(chan struct{})
+		lcd.animations = &sync.WaitGroup{}
+	}
+	lcd.animations.Add(1)
+	return lcd.animationDone, lcd.animations.Done
+}
+
+// stopAnimations signals all running animations to stop and waits up to
+// timeout for them to return.
+func (lcd *Lcd) stopAnimations(timeout time.Duration) {
+	lcd.animationMutex.Lock()
+	animations := lcd.animations
+	if lcd.animationDone != nil {
+		close(lcd.animationDone)
+		lcd.animationDone, lcd.animations = nil, nil
+	}
+	lcd.animationMutex.Unlock()
+	if animations == nil {
+		return
+	}
+	finished := make(chan struct{})
+	go func() {
+		animations.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(timeout):
+		lg.Warningf("Animations still running %v after shutdown\n", timeout)
+	}
+}
+
+// AnimationControl pauses, resumes and stops the animations started with
+// it, e.g. SpinnerWithControl, to freeze them while a dialog covers the
+// display. Done can also be passed as stop channel to the other animations.
+type AnimationControl struct {
+	mutex  sync.Mutex
+	paused bool
+	done   chan struct{}
+}
+
+// NewAnimationControl creates a control for running animations.
+func NewAnimationControl() *AnimationControl {
+	return &AnimationControl{done: make(chan struct{})}
+}
+
+// Pause freezes the animations on their current frame.
+func (c *AnimationControl) Pause() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.paused = true
+}
+
+// Resume continues paused animations from the frame they were paused at.
+func (c *AnimationControl) Resume() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.paused = false
+}
+
+// Stop ends the animations, like closing their stop channel. Calling it
+// again does nothing.
+func (c *AnimationControl) Stop() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	select {
+	case <-c.done:
+	default:
+		close(c.done)
+	}
+}
+
+// Done returns a channel that's closed by Stop.
+func (c *AnimationControl) Done() <-chan struct{} {
+	return c.done
+}
+
+// Paused reports whether the animations are paused.
+func (c *AnimationControl) Paused() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.paused
+}
+
+// running reports whether an animation should draw its next frame, which
+// it does unless it has a paused check that says otherwise.
+func running(paused func() bool) bool {
+	return paused == nil || !paused()
+}
+
+// Backslash is not available in the A00 character ROM (0x5C shows a yen
+// sign), so the spinner draws it from CGRAM.
+var glyphBackslash = [8]byte{0x00, 0x10, 0x08, 0x04, 0x02, 0x01, 0x00, 0x00}
+
+// Spinner shows a rotating "- \ | /" indicator at the specified position,
+// advancing one frame every interval, until stop is closed. The cell is
+// blanked when the spinner stops. Spinner blocks, so run it in its own
+// goroutine and synchronize with other output to the display.
+func (lcd *Lcd) Spinner(line, col int, interval time.Duration, stop <-chan struct{}) error {
+	return lcd.spinner(line, col, interval, stop, nil)
+}
+
+// SpinnerWithControl works like Spinner, but is paused, resumed and
+// stopped through ctl.
+func (lcd *Lcd) SpinnerWithControl(line, col int, interval time.Duration,
+	ctl *AnimationControl) error {
+	return lcd.spinner(line, col, interval, ctl.Done(), ctl.Paused)
+}
+
+func (lcd *Lcd) spinner(line, col int, interval time.Duration,
+	stop <-chan struct{}, paused func() bool) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	backslash := byte('\\')
+	if index, err := lcd.RegisterGlyph(glyphBackslash); err == nil {
+		backslash = byte(index)
+	} else if err != ErrNoFreeGlyph {
+		return err
+	}
+	frames := []byte{'-', backslash, '|', '/'}
+
+	shutdown, done := lcd.startAnimation()
+	defer done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := 0; ; {
+		if !lcd.active.Load() {
+			return lcd.inactiveErr()
+		}
+		if running(paused) {
+			err := lcd.writeCharAt(line, col, frames[i%len(frames)])
+			if err != nil {
+				return err
+			}
+			i++
+		}
+		select {
+		case <-shutdown:
+			return lcd.inactiveErr()
+		case <-stop:
+			return lcd.writeCharAt(line, col, ' ')
+		case <-ticker.C:
+		}
+	}
+}
+
+// SoftBlink alternates between glyph and blankGlyph at the specified
+// position, switching every interval, until stop is closed. Unlike the
+// hardware blink (BlinkOn) the rate is freely adjustable. When stopped,
+// glyph is left on the display.
+func (lcd *Lcd) SoftBlink(line, col int, glyph, blankGlyph rune,
+	interval time.Duration, stop <-chan struct{}) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	shutdown, done := lcd.startAnimation()
+	defer done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	frames := []byte{byte(glyph), byte(blankGlyph)}
+	for i := 0; ; i++ {
+		if !lcd.active.Load() {
+			return lcd.inactiveErr()
+		}
+		err := lcd.writeCharAt(line, col, frames[i%len(frames)])
+		if err != nil {
+			return err
+		}
+		select {
+		case <-shutdown:
+			return lcd.inactiveErr()
+		case <-stop:
+			return lcd.writeCharAt(line, col, byte(glyph))
+		case <-ticker.C:
+		}
+	}
+}
+
+// bounce moves pos by delta within [0..max], reversing delta at the ends.
+func bounce(pos, delta, max int) (int, int) {
+	if max <= 0 {
+		return 0, delta
+	}
+	if pos+delta < 0 || pos+delta > max {
+		delta = -delta
+	}
+	return pos + delta, delta
+}
+
+// Screensaver clears the display and bounces text around the screen,
+// moving it one line and one position diagonally every interval, until
+// stop is closed. Text wider than the display is truncated. The display
+// is cleared again when the screensaver stops.
+func (lcd *Lcd) Screensaver(text string, interval time.Duration, stop <-chan struct{}) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	w, h := lcd.getSize()
+	if w == -1 {
+		return errors.New("Can't run screensaver on a display with unknown size")
+	}
+	runes := []rune(text)
+	if len(runes) > w {
+		runes = runes[:w]
+	}
+	blank := strings.Repeat(" ", len(runes))
+	err := lcd.Clear()
+	if err != nil {
+		return err
+	}
+
+	shutdown, done := lcd.startAnimation()
+	defer done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	line, pos, dLine, dPos := 0, 0, 1, 1
+	for {
+		if !lcd.active.Load() {
+			return lcd.inactiveErr()
+		}
+		err = lcd.writeText(line, pos, string(runes))
+		if err != nil {
+			return err
+		}
+		select {
+		case <-shutdown:
+			return lcd.inactiveErr()
+		case <-stop:
+			return lcd.Clear()
+		case <-ticker.C:
+		}
+		// Clear the previous location
+		err = lcd.writeText(line, pos, blank)
+		if err != nil {
+			return err
+		}
+		line, dLine = bounce(line, dLine, h-1)
+		pos, dPos = bounce(pos, dPos, w-len(runes))
+	}
+}
+
+// AnimateGlyph cycles CGRAM slot index through frames, uploading the next
+// pattern every interval, until stop is closed. Every cell showing that
+// glyph animates without rewriting any DDRAM. When stopped, the first frame
+// is put back. Like the other animations AnimateGlyph blocks.
+func (lcd *Lcd) AnimateGlyph(index int, frames [][8]byte,
+	interval time.Duration, stop <-chan struct{}) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	if len(frames) == 0 {
+		return errors.New("Glyph animation needs at least one frame")
+	}
+	err := checkGlyphIndex(index)
+	if err != nil {
+		return err
+	}
+
+	shutdown, done := lcd.startAnimation()
+	defer done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		if !lcd.active.Load() {
+			return lcd.inactiveErr()
+		}
+		err = lcd.DefineChar(index, frames[i%len(frames)])
+		if err != nil {
+			return err
+		}
+		select {
+		case <-shutdown:
+			return lcd.inactiveErr()
+		case <-stop:
+			return lcd.DefineChar(index, frames[0])
+		case <-ticker.C:
+		}
+	}
+}
+
+// scrollFieldGap separates the end of the text from its start when
+// ScrollField cycles it.
+const scrollFieldGap = "   "
+
+// scrollWindow returns the width characters of text visible at offset,
+// with text repeating after scrollFieldGap, and padded with spaces to at
+// least width so it scrolls across the whole window.
+func scrollWindow(text []rune, width, offset int) []rune {
+	cycle := append(append([]rune{}, text...), []rune(scrollFieldGap)...)
+	for len(cycle) < width {
+		cycle = append(cycle, ' ')
+	}
+	offset %= len(cycle)
+	if offset < 0 {
+		offset += len(cycle)
+	}
+	window := make([]rune, width)
+	for j := range window {
+		window[j] = cycle[(offset+j)%len(cycle)]
+	}
+	return window
+}
+
+// ScrollField shows text in the field of width characters at line, col,
+// and if it's longer than the field, moves it one character to the left
+// every interval, starting over with the beginning of the text after the
+// end. Text that fits is written once. ScrollField blocks until stop is
+// closed, and then leaves the beginning of the text in the field.
+func (lcd *Lcd) ScrollField(line, col, width int, text string,
+	interval time.Duration, stop <-chan struct{}) error {
+	return lcd.scrollField(line, col, width, text, interval, stop, nil)
+}
+
+// ScrollFieldWithControl works like ScrollField, but is paused, resumed
+// and stopped through ctl.
+func (lcd *Lcd) ScrollFieldWithControl(line, col, width int, text string,
+	interval time.Duration, ctl *AnimationControl) error {
+	return lcd.scrollField(line, col, width, text, interval, ctl.Done(), ctl.Paused)
+}
+
+func (lcd *Lcd) scrollField(line, col, width int, text string,
+	interval time.Duration, stop <-chan struct{}, paused func() bool) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	err := lcd.checkRect(line, col, width, 1)
+	if err != nil {
+		return err
+	}
+	runes := []rune(text)
+	scrolls := len(runes) > width
+
+	shutdown, done := lcd.startAnimation()
+	defer done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := 0; ; {
+		if !lcd.active.Load() {
+			return lcd.inactiveErr()
+		}
+		if (scrolls || i == 0) && running(paused) {
+			window := runes
+			if scrolls {
+				window = scrollWindow(runes, width, i)
+			}
+			err = lcd.WriteField(line, col, width, string(window))
+			if err != nil {
+				return err
+			}
+			i++
+		}
+		select {
+		case <-shutdown:
+			return lcd.inactiveErr()
+		case <-stop:
+			return lcd.WriteField(line, col, width, text)
+		case <-ticker.C:
+		}
+	}
+}
+
+// ScrollLineLeft shows text on line moved offset characters to the left,
+// repeating after a short gap, and leaves the other lines alone. Calling
+// it with an increasing offset scrolls just that line, unlike
+// ScrollDisplayLeft, which moves all of them.
+func (lcd *Lcd) ScrollLineLeft(line int, text string, offset int) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	w, _ := lcd.getSize()
+	if w == -1 {
+		return errors.New("Can't scroll a line on a display with unknown size")
+	}
+	return lcd.WriteField(line, 0, w, string(scrollWindow([]rune(text), w, offset)))
+}
+
+// heartbeatFrames are the glyphs Heartbeat cycles through.
+var heartbeatFrames = [][8]byte{
+	Glyphs["heart"],
+	{0x00, 0x00, 0x0A, 0x0E, 0x04, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00},
+	{0x00, 0x00, 0x0A, 0x0E, 0x04, 0x00, 0x00, 0x00},
+}
+
+// Heartbeat advances a pulsing heart at line, col by one frame per call,
+// for loops that already run at a steady rate and don't want to manage
+// a Spinner goroutine. The heart takes one CGRAM slot, which is redefined
+// for every frame; if none is free, '*' blinks instead.
+func (lcd *Lcd) Heartbeat(line, col int) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	frame := heartbeatFrames[lcd.heartbeatFrame%len(heartbeatFrames)]
+	prev := heartbeatFrames[(lcd.heartbeatFrame+len(heartbeatFrames)-1)%len(heartbeatFrames)]
+	lcd.heartbeatFrame++
+	// Take a new slot if ours was released or redefined by someone else
+	if !lcd.heartbeatOwned || !lcd.glyphUsed[lcd.heartbeatSlot] ||
+		lcd.glyphs[lcd.heartbeatSlot] != prev {
+		lcd.heartbeatOwned = false
+		index, err := lcd.allocGlyph(frame)
+		if err == ErrNoFreeGlyph {
+			c := byte('*')
+			if lcd.heartbeatFrame%2 == 0 {
+				c = ' '
+			}
+			return lcd.writeCharAt(line, col, c)
+		} else if err != nil {
+			return err
+		}
+		lcd.heartbeatSlot, lcd.heartbeatOwned = index, true
+	} else {
+		err := lcd.DefineChar(lcd.heartbeatSlot, frame)
+		if err != nil {
+			return err
+		}
+	}
+	return lcd.writeCharAt(line, col, byte(lcd.heartbeatSlot))
+}
@@ -0,0 +1,49 @@
+package hd44780
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStopAnimationsAfterTimeout(t *testing.T) {
+	lcd, _ := newTestLcd(t, WithType(LCD_16x2))
+	// An animation that doesn't return in time keeps the first
+	// stopAnimations waiting in the background
+	_, stuckDone := lcd.startAnimation()
+	lcd.stopAnimations(time.Millisecond)
+
+	shutdown, done := lcd.startAnimation()
+	select {
+	case <-shutdown:
+		t.Fatal("Animation started after stopAnimations is already stopped")
+	default:
+	}
+	stuckDone()
+	go func() {
+		<-shutdown
+		done()
+	}()
+	start := time.Now()
+	lcd.stopAnimations(time.Second)
+	if d := time.Since(start); d >= time.Second {
+		t.Errorf("stopAnimations waited %v for an animation that had stopped", d)
+	}
+}
+
+func TestShutdownStopsSpinner(t *testing.T) {
+	lcd, _ := newTestLcd(t, WithType(LCD_16x2))
+	stop := make(chan struct{})
+	defer close(stop)
+	result := make(chan error, 1)
+	go func() {
+		result <- lcd.Spinner(0, 0, time.Millisecond, stop)
+	}()
+	// Run with -race: Shutdown flips active while the spinner checks it
+	time.Sleep(10 * time.Millisecond)
+	lcd.Shutdown()
+	select {
+	case <-result:
+	case <-time.After(time.Second):
+		t.Fatal("Spinner still runs after Shutdown")
+	}
+}
@@ -0,0 +1,92 @@
+package hd44780
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// backlightPWMPeriod is the cycle time of SetBacklightLevel, fast enough
+// not to flicker visibly.
+const backlightPWMPeriod = 10 * time.Millisecond
+
+// SetBacklightLevel dims the backlight to level (0..1) by switching it
+// on and off from a background goroutine, with the share of on time
+// matching level. This only works on backpacks that switch the backlight
+// through a transistor on PIN_BACKLIGHT; with a jumper or a fixed resistor
+// it stays as it is. Level 0 and 1 work like BacklightOff and BacklightOn,
+// which also end the dimming.
+func (lcd *Lcd) SetBacklightLevel(level float64) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	if math.IsNaN(level) || level < 0 || level > 1 {
+		return fmt.Errorf("Backlight level %v must be within the range [0..1]", level)
+	}
+	switch level {
+	case 0:
+		return lcd.BacklightOff()
+	case 1:
+		return lcd.BacklightOn()
+	}
+	lcd.stopBacklightPWM()
+	lcd.cancelBacklightPulse()
+	// Other output keeps the backlight on during its writes
+	lcd.setBacklightState(true)
+	on := time.Duration(level * float64(backlightPWMPeriod))
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	lcd.pwmStop, lcd.pwmDone = stop, done
+	lcd.pwmLevel = level
+	shutdown, finished := lcd.startAnimation()
+	go func() {
+		defer close(done)
+		defer finished()
+		for {
+			for _, phase := range []struct {
+				lit bool
+				d   time.Duration
+			}{{true, on}, {false, backlightPWMPeriod - on}} {
+				err := lcd.writeBacklightPin(phase.lit)
+				if err != nil {
+					lg.Warningf("Backlight dimming stopped: %v\n", err)
+					return
+				}
+				select {
+				case <-shutdown:
+					return
+				case <-stop:
+					return
+				case <-time.After(phase.d):
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// stopBacklightPWM ends dimming started by SetBacklightLevel, if any, and
+// waits for it to finish.
+func (lcd *Lcd) stopBacklightPWM() {
+	if lcd.pwmStop == nil {
+		return
+	}
+	close(lcd.pwmStop)
+	<-lcd.pwmDone
+	lcd.pwmStop, lcd.pwmDone = nil, nil
+	lcd.pwmLevel = 0
+}
+
+// writeBacklightPin sets just the backlight pin, between two bytes sent
+// by writeByte, so the controller doesn't notice.
+func (lcd *Lcd) writeBacklightPin(lit bool) error {
+	lcd.busMutex.Lock()
+	defer lcd.busMutex.Unlock()
+	var data byte
+	if lit != lcd.backlightInvert {
+		data = PIN_BACKLIGHT
+	}
+	return lcd.writeBus([]byte{lcd.output(data)})
+}
@@ -0,0 +1,99 @@
+package hd44780
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBacklightLevelWithBufferedWrites(t *testing.T) {
+	lcd, _ := newTestLcd(t, WithType(LCD_16x2))
+	err := lcd.SetBufferedWrites(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lcd.SetBacklightLevel(0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lcd.BacklightOff()
+	// Run with -race: buffered transfers go out while the dimming toggles
+	for i := 0; i < 20; i++ {
+		err = lcd.WriteAt(0, 0, "dimmed")
+		if err == nil {
+			err = lcd.Flush()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSleepPausesBacklightLevel(t *testing.T) {
+	lcd, bus := newTestLcd(t, WithType(LCD_16x2))
+	err := lcd.SetBacklightLevel(0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lcd.BacklightOff()
+	err = lcd.Sleep()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lcd.pwmStop != nil {
+		t.Fatal("Backlight dimming still runs after Sleep")
+	}
+	before := bus.writeCount()
+	time.Sleep(3 * backlightPWMPeriod)
+	if after := bus.writeCount(); after != before {
+		t.Errorf("%d bus writes while asleep, want none", after-before)
+	}
+	err = lcd.Wake()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lcd.pwmStop == nil || lcd.pwmLevel != 0.5 {
+		t.Errorf("Wake left the backlight at level %v, want 0.5 dimming", lcd.pwmLevel)
+	}
+}
+
+func TestBacklightPulse(t *testing.T) {
+	lcd, bus := newTestLcd(t, WithType(LCD_16x2))
+	lcd.SetBacklightPulse(5 * time.Millisecond)
+	err := lcd.BacklightOff()
+	if err == nil {
+		err = lcd.pulseBacklight()
+	}
+	if err == nil {
+		err = lcd.Sleep()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The pulse ends while asleep, which must leave the bus alone
+	before := bus.writeCount()
+	time.Sleep(20 * time.Millisecond)
+	if after := bus.writeCount(); after != before {
+		t.Errorf("%d bus writes from a pulse ending while asleep, want none", after-before)
+	}
+	err = lcd.Wake()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lcd.backlightState() {
+		t.Error("Wake turned the backlight on, it was off before the pulse")
+	}
+
+	// An explicit setting replaces the pending pulse
+	err = lcd.pulseBacklight()
+	if err == nil {
+		err = lcd.BacklightOn()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !lcd.backlightState() {
+		t.Error("Pulse ending turned off the backlight after BacklightOn")
+	}
+}
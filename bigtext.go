@@ -0,0 +1,111 @@
+package hd44780
+
+import "unicode"
+
+// DrawTall renders text in double-height letters across lines 0 and 1,
+// starting at startCol. Digits and letters (shown as capitals) are drawn
+// from CGRAM, two glyphs per distinct character, so at most 4 distinct
+// characters can be tall at once. Other runes, and characters for which
+// no CGRAM slot is left, are shown as normal characters on line 1.
+// Text is cut off at the end of the line. DrawTall owns the slots it
+// uploads and releases those the next call doesn't need, so tall text of
+// an earlier call that isn't drawn over may change.
+func (lcd *Lcd) DrawTall(startCol int, text string) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	w, _ := lcd.getSize()
+	var runes []rune
+	for _, c := range text {
+		if w != -1 && startCol+len(runes) >= w {
+			break
+		}
+		runes = append(runes, c)
+	}
+	// Free the slots of halves no longer shown, then upload new ones
+	needed := make(map[[8]byte]bool)
+	for _, c := range runes {
+		if bitmap, ok := font5x7[unicode.ToUpper(c)]; ok {
+			t, b := tallHalves(bitmap)
+			needed[t], needed[b] = true, true
+		}
+	}
+	for pattern, index := range lcd.tallSlots {
+		if !needed[pattern] {
+			err := lcd.ReleaseGlyph(index)
+			if err != nil {
+				return err
+			}
+			delete(lcd.tallSlots, pattern)
+		}
+	}
+	var top, bottom []byte
+	// Upload all glyphs first, so the characters go out in one run
+	for _, c := range runes {
+		t, b, ok, err := lcd.tallGlyphs(unicode.ToUpper(c))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			t, b = ' ', byte(c)
+		}
+		top = append(top, t)
+		bottom = append(bottom, b)
+	}
+	for line, codes := range [][]byte{top, bottom} {
+		err := lcd.SetPosition(line, startCol)
+		if err != nil {
+			return err
+		}
+		for i, c := range codes {
+			err = lcd.skipAddressGap(line, startCol+i)
+			if err != nil {
+				return err
+			}
+			err = lcd.writeByte(c, PIN_RS)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tallGlyphs uploads the top and bottom half glyphs of c into slots owned
+// by DrawTall, returning ok false when c has no bitmap or no CGRAM slots
+// are left. Then no slot is taken for c.
+func (lcd *Lcd) tallGlyphs(c rune) (top, bottom byte, ok bool, err error) {
+	bitmap, found := font5x7[c]
+	if !found {
+		return 0, 0, false, nil
+	}
+	if lcd.tallSlots == nil {
+		lcd.tallSlots = make(map[[8]byte]int)
+	}
+	t, b := tallHalves(bitmap)
+	var indexes [2]int
+	var allocated [][8]byte
+	for i, pattern := range [][8]byte{t, b} {
+		index, found := lcd.tallSlots[pattern]
+		if !found {
+			index, err = lcd.allocGlyph(pattern)
+			if err != nil {
+				// A half alone is of no use, free the other one
+				for _, p := range allocated {
+					lcd.ReleaseGlyph(lcd.tallSlots[p])
+					delete(lcd.tallSlots, p)
+				}
+				if err == ErrNoFreeGlyph {
+					return 0, 0, false, nil
+				}
+				return 0, 0, false, err
+			}
+			lcd.tallSlots[pattern] = index
+			allocated = append(allocated, pattern)
+		}
+		indexes[i] = index
+	}
+	return byte(indexes[0]), byte(indexes[1]), true, nil
+}
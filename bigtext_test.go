@@ -0,0 +1,40 @@
+package hd44780
+
+import "testing"
+
+func TestDrawTallReleasesSlots(t *testing.T) {
+	lcd, bus := newTestLcd(t, WithType(LCD_16x2))
+	for _, text := range []string{"12", "34", "56"} {
+		bus.reset()
+		err := lcd.DrawTall(0, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The last 4 bytes sent are the bottom halves
+		got := bus.sent()
+		for _, s := range got[len(got)-len(text):] {
+			if s.data >= CGRAM_Glyphs {
+				t.Errorf("DrawTall(%q) fell back to plain characters: %v", text, got)
+				break
+			}
+		}
+	}
+}
+
+func TestDrawTallFreesTopWithoutBottom(t *testing.T) {
+	lcd, _ := newTestLcd(t, WithType(LCD_16x2))
+	// Leave a single free slot, enough for a top half only
+	for i := 0; i < CGRAM_Glyphs-1; i++ {
+		_, err := lcd.allocGlyph([8]byte{byte(i + 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := lcd.DrawTall(0, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lcd.glyphUsed[CGRAM_Glyphs-1] {
+		t.Error("DrawTall kept the top half slot without a bottom half")
+	}
+}
@@ -0,0 +1,194 @@
+package hd44780
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// calibrationPattern is written to the last CGRAM glyph and read back,
+// to check whether the display still keeps up with a strobe delay.
+var calibrationPattern = [8]byte{0x15, 0x0A, 0x15, 0x0A, 0x1F, 0x00, 0x11, 0x0E}
+
+// strobeWorks reports whether calibrationPattern survives a round trip
+// through CGRAM with the specified write strobe delay.
+func (lcd *Lcd) strobeWorks(writeDelay uint16) (bool, error) {
+	lcd.writeStrobeDelay = writeDelay
+	addr := CMD_CGRAM_Set | byte((CGRAM_Glyphs-1)<<3)
+	err := lcd.writeByte(addr, 0)
+	if err != nil {
+		return false, err
+	}
+	for _, row := range calibrationPattern {
+		err = lcd.writeByte(row, PIN_RS)
+		if err != nil {
+			return false, err
+		}
+	}
+	err = lcd.writeByte(addr, 0)
+	if err != nil {
+		return false, err
+	}
+	for _, row := range calibrationPattern {
+		b, err := lcd.readByte(true)
+		if err != nil {
+			return false, err
+		}
+		if b&0x1F != row {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// CalibrateStrobe measures the shortest WRITE strobe delay the display
+// reliably works with, by binary search between 1µs and the current
+// delay, and sets it with a 50% safety margin. Every step is verified by
+// writing and reading back CGRAM slot 7, which is restored afterwards.
+// The busy flag is read first to check that the display answers reads;
+// if it doesn't (RW grounded), the default delays are set instead.
+func (lcd *Lcd) CalibrateStrobe() (err error) {
+	line, pos := lcd.cursorLine, lcd.cursorPos
+	err = lcd.waitBusy()
+	if err == ErrReadUnsupported {
+		lg.Infof("Busy flag can't be read, using default strobe delays\n")
+		lcd.SetStrobeDelays(defaultWriteStrobeDelay, defaultResetStrobeDelay)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Put back the glyph and cursor position the test overwrote, also
+	// when calibration fails
+	defer func() {
+		restoreErr := lcd.restoreCalibrationGlyph(line, pos)
+		if err == nil {
+			err = restoreErr
+		}
+	}()
+
+	original := lcd.writeStrobeDelay
+	lo, hi := uint16(1), original
+	ok, err := lcd.strobeWorks(hi)
+	if err == nil && !ok {
+		err = fmt.Errorf("Display is unreliable at the current "+
+			"write strobe delay of %dµs", original)
+	}
+	for err == nil && lo < hi {
+		mid := lo + (hi-lo)/2
+		ok, err = lcd.strobeWorks(mid)
+		if ok {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	if err != nil {
+		lcd.writeStrobeDelay = original
+		return err
+	}
+	delay := hi + hi/2
+	if delay > original {
+		delay = original
+	}
+	lcd.writeStrobeDelay = delay
+	lg.Debugf("Calibrated write strobe delay: %dµs (minimum %dµs)\n", delay, hi)
+	return nil
+}
+
+// restoreCalibrationGlyph puts back CGRAM slot 7 after strobeWorks wrote
+// to it, and with it DDRAM addressing at line, pos.
+func (lcd *Lcd) restoreCalibrationGlyph(line, pos int) error {
+	last := CGRAM_Glyphs - 1
+	if lcd.glyphUsed[last] {
+		// DefineChar returns to the tracked position, which is line, pos
+		return lcd.DefineChar(last, lcd.glyphs[last])
+	}
+	return lcd.restoreCursor(line, pos)
+}
+
+// MeasureThroughput writes sampleChars filler characters to the first line,
+// over and over, and returns how many characters per second got through,
+// e.g. to compare strobe delay settings. The display is cleared afterwards.
+func (lcd *Lcd) MeasureThroughput(sampleChars int) (float64, error) {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return 0, lcd.inactiveErr()
+	}
+
+	if sampleChars < 1 {
+		return 0, fmt.Errorf("Sample of %d characters must not be empty", sampleChars)
+	}
+	w, _ := lcd.getSize()
+	if w == -1 {
+		w = 8
+	}
+	start := time.Now()
+	for i := 0; i < sampleChars; i++ {
+		if i%w == 0 {
+			err := lcd.SetPosition(0, 0)
+			if err != nil {
+				return 0, err
+			}
+		}
+		err := lcd.skipAddressGap(0, i%w)
+		if err != nil {
+			return 0, err
+		}
+		err = lcd.writeByte(byte('0'+i%10), PIN_RS)
+		if err != nil {
+			return 0, err
+		}
+	}
+	err := lcd.Flush()
+	if err != nil {
+		return 0, err
+	}
+	rate := float64(sampleChars) / time.Since(start).Seconds()
+	lg.Debugf("Measured throughput: %.0f characters/s\n", rate)
+	return rate, lcd.Clear()
+}
+
+// i2cByteTime is roughly how long one byte takes on a 100kHz I2C bus,
+// including its acknowledge.
+const i2cByteTime = 90 * time.Microsecond
+
+// byteDuration estimates how long writeByte blocks: two strobed nibbles
+// of three bus bytes each, at least minWriteInterval apart.
+func (lcd *Lcd) byteDuration() time.Duration {
+	var d time.Duration
+	if lcd.buffered {
+		// Delays are dropped and the bytes go out in bulk
+		d = 6 * i2cByteTime
+	} else {
+		// Every bus byte is its own transfer, after the device address
+		// and the register of register based expanders
+		transfer := 2 * i2cByteTime
+		if lcd.pins.Register != 0 {
+			transfer += i2cByteTime
+		}
+		strobe := (time.Duration(lcd.settleDelay) + time.Duration(lcd.writeStrobeDelay) +
+			time.Duration(lcd.resetStrobeDelay)) * time.Microsecond
+		d = 2 * (3*transfer + strobe)
+	}
+	if d < lcd.minWriteInterval {
+		d = lcd.minWriteInterval
+	}
+	return d
+}
+
+// EstimateWriteDuration estimates how long ShowMessage(text, options)
+// will block, from the number of characters and cursor moves it sends and
+// the current delays, assuming a 100kHz I2C bus. Use it e.g. to decide
+// whether to update the display from a separate goroutine.
+func (lcd *Lcd) EstimateWriteDuration(text string, options ShowOptions) time.Duration {
+	var bytes int
+	for _, line := range lcd.splitText(text, options) {
+		// One cursor move per line, plus one at the gap of LCD_16x1
+		bytes += 1 + utf8.RuneCountInString(line)
+		if lcd.lcdType == LCD_16x1 && utf8.RuneCountInString(line) > 8 {
+			bytes++
+		}
+	}
+	return time.Duration(bytes) * lcd.byteDuration()
+}
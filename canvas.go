@@ -0,0 +1,156 @@
+package hd44780
+
+import "fmt"
+
+// Canvas is a pixel-addressable area of character cells, drawn with CGRAM
+// glyphs. Each cell is 5x8 pixels, and since every cell may need its own
+// glyph a canvas spans at most CGRAM_Glyphs (8) cells, e.g. 8x1 cells
+// (40x8 pixels) or 4x2 cells (20x16 pixels). Note that the display shows
+// a small gap between cells, so pixels of neighbouring cells don't touch.
+type Canvas struct {
+	lcd        *Lcd
+	line, col  int
+	cols, rows int
+	cells      [][8]byte
+	slots      map[[8]byte]int
+}
+
+// NewCanvas creates a blank canvas of cols x rows character cells, with its
+// top-left cell at the specified line and position. Nothing is drawn
+// until Render is called.
+func NewCanvas(lcd *Lcd, line, col, cols, rows int) (*Canvas, error) {
+	if cols < 1 || rows < 1 || cols*rows > CGRAM_Glyphs {
+		return nil, fmt.Errorf("Canvas of %dx%d cells "+
+			"must have between 1 and %d cells", cols, rows, CGRAM_Glyphs)
+	}
+	w, h := lcd.getSize()
+	if (w != -1 && col+cols > w) || (h != -1 && line+rows > h) ||
+		line < 0 || col < 0 {
+		return nil, fmt.Errorf("Canvas of %dx%d cells at line %d, position %d "+
+			"doesn't fit on the display", cols, rows, line, col)
+	}
+	c := &Canvas{lcd: lcd, line: line, col: col, cols: cols, rows: rows,
+		cells: make([][8]byte, cols*rows),
+		slots: make(map[[8]byte]int),
+	}
+	return c, nil
+}
+
+// Width returns the canvas width in pixels.
+func (c *Canvas) Width() int {
+	return c.cols * 5
+}
+
+// Height returns the canvas height in pixels.
+func (c *Canvas) Height() int {
+	return c.rows * 8
+}
+
+// SetPixel turns the pixel at x, y on or off, counting from the top-left
+// corner. Pixels outside the canvas are ignored. The change becomes
+// visible with the next Render.
+func (c *Canvas) SetPixel(x, y int, on bool) {
+	if x < 0 || y < 0 || x >= c.Width() || y >= c.Height() {
+		return
+	}
+	cell := &c.cells[(y/8)*c.cols+x/5]
+	bit := byte(0x10) >> uint(x%5)
+	if on {
+		cell[y%8] |= bit
+	} else {
+		cell[y%8] &^= bit
+	}
+}
+
+// Pixel reports whether the pixel at x, y is on.
+func (c *Canvas) Pixel(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Width() || y >= c.Height() {
+		return false
+	}
+	return c.cells[(y/8)*c.cols+x/5][y%8]&(0x10>>uint(x%5)) != 0
+}
+
+// Clear turns all pixels off. The change becomes visible with the next Render.
+func (c *Canvas) Clear() {
+	for i := range c.cells {
+		c.cells[i] = [8]byte{}
+	}
+}
+
+// Shift moves the whole picture by dx pixels to the right and dy pixels
+// down, negative values moving it left and up. Pixels moved off the canvas
+// are lost and uncovered ones are off. The change becomes visible with
+// the next Render.
+func (c *Canvas) Shift(dx, dy int) {
+	old := make([][8]byte, len(c.cells))
+	copy(old, c.cells)
+	src := &Canvas{cols: c.cols, rows: c.rows, cells: old}
+	c.Clear()
+	for y := 0; y < c.Height(); y++ {
+		for x := 0; x < c.Width(); x++ {
+			if src.Pixel(x-dx, y-dy) {
+				c.SetPixel(x, y, true)
+			}
+		}
+	}
+}
+
+// Render uploads the glyphs the canvas needs and draws its cells. Blank
+// cells are drawn as spaces and identical cells share a glyph, so only
+// patterns that actually occur hold CGRAM slots. The canvas owns these
+// slots and releases them once their pattern is no longer shown.
+func (c *Canvas) Render() error {
+	//Not active, so don't try do anything
+	if !c.lcd.active.Load() {
+		return c.lcd.inactiveErr()
+	}
+
+	needed := make(map[[8]byte]bool)
+	for _, cell := range c.cells {
+		if cell != ([8]byte{}) {
+			needed[cell] = true
+		}
+	}
+	// Free the slots of patterns no longer shown, then upload new ones
+	for pattern, index := range c.slots {
+		if !needed[pattern] {
+			err := c.lcd.ReleaseGlyph(index)
+			if err != nil {
+				return err
+			}
+			delete(c.slots, pattern)
+		}
+	}
+	for pattern := range needed {
+		if _, ok := c.slots[pattern]; ok {
+			continue
+		}
+		index, err := c.lcd.allocGlyph(pattern)
+		if err != nil {
+			return err
+		}
+		c.slots[pattern] = index
+	}
+
+	for row := 0; row < c.rows; row++ {
+		err := c.lcd.SetPosition(c.line+row, c.col)
+		if err != nil {
+			return err
+		}
+		for col := 0; col < c.cols; col++ {
+			var code byte = ' '
+			if index, ok := c.slots[c.cells[row*c.cols+col]]; ok {
+				code = byte(index)
+			}
+			err = c.lcd.skipAddressGap(c.line+row, c.col+col)
+			if err != nil {
+				return err
+			}
+			err = c.lcd.writeByte(code, PIN_RS)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package hd44780
+
+import "fmt"
+
+// CharROM identifies the character ROM variant of the controller, which
+// decides the characters shown for codes outside of plain ASCII.
+type CharROM int
+
+const (
+	// ROM_A00 is the Japanese ROM with katakana, found on most displays
+	ROM_A00 CharROM = iota
+	// ROM_A02 is the European ROM with accented Latin and Cyrillic letters
+	ROM_A02
+)
+
+// romA00 maps runes outside of plain ASCII to their codes in the A00
+// (Japanese) character ROM, the one fitted to most displays.
+var romA00 = map[rune]byte{
+	'¥': 0x5C, '→': 0x7E, '←': 0x7F, '·': 0xA5,
+	'°': 0xDF, 'α': 0xE0, 'ä': 0xE1, 'β': 0xE2, 'ß': 0xE2, 'ε': 0xE3,
+	'µ': 0xE4, 'μ': 0xE4, 'σ': 0xE5, 'ρ': 0xE6, '√': 0xE8, '¢': 0xEC,
+	'ñ': 0xEE, 'ö': 0xEF, 'θ': 0xF2, '∞': 0xF3, 'Ω': 0xF4, 'ü': 0xF5,
+	'Σ': 0xF6, 'π': 0xF7, '÷': 0xFD, '█': 0xFF,
+}
+
+// miniFont holds 5x8 bitmaps of common characters missing from the A00
+// ROM, which WriteMapped uploads into CGRAM when they're needed.
+var miniFont = map[rune][8]byte{
+	'\\': glyphBackslash,
+	'~':  {0x00, 0x00, 0x08, 0x15, 0x02, 0x00, 0x00, 0x00},
+	'€':  {0x06, 0x09, 0x1C, 0x08, 0x1C, 0x09, 0x06, 0x00},
+	'£':  {0x06, 0x09, 0x08, 0x1C, 0x08, 0x08, 0x1F, 0x00},
+	'§':  {0x0E, 0x10, 0x0E, 0x11, 0x0E, 0x01, 0x0E, 0x00},
+	'Ä':  {0x0A, 0x00, 0x0E, 0x11, 0x1F, 0x11, 0x11, 0x00},
+	'Ö':  {0x0A, 0x00, 0x0E, 0x11, 0x11, 0x11, 0x0E, 0x00},
+	'Ü':  {0x0A, 0x00, 0x11, 0x11, 0x11, 0x11, 0x0E, 0x00},
+	'à':  {0x08, 0x04, 0x0E, 0x01, 0x0F, 0x11, 0x0F, 0x00},
+	'é':  {0x02, 0x04, 0x0E, 0x11, 0x1F, 0x10, 0x0E, 0x00},
+	'è':  {0x08, 0x04, 0x0E, 0x11, 0x1F, 0x10, 0x0E, 0x00},
+	'ç':  {0x00, 0x0E, 0x10, 0x10, 0x11, 0x0E, 0x04, 0x0C},
+}
+
+// romChar returns the character ROM code showing r, if there is one.
+func (lcd *Lcd) romChar(r rune) (byte, bool) {
+	if lcd.charROM == ROM_A02 {
+		// Complete ASCII, and Latin-1 from 0xA0 on at the same codes
+		if r >= ' ' && r <= '~' || r >= 0xA0 && r <= 0xFF {
+			return byte(r), true
+		}
+		return 0, false
+	}
+	if r >= ' ' && r <= '}' && r != '\\' {
+		return byte(r), true
+	}
+	code, ok := romA00[r]
+	return code, ok
+}
+
+// lookupRune returns the character code showing r: its ROM code, or else
+// a CGRAM glyph registered from miniFont. It reports false for runes
+// without either, or once all CGRAM slots are taken.
+func (lcd *Lcd) lookupRune(r rune) (byte, bool, error) {
+	if code, ok := lcd.romChar(r); ok {
+		return code, true, nil
+	}
+	pattern, ok := miniFont[r]
+	if !ok {
+		return 0, false, nil
+	}
+	index, err := lcd.RegisterGlyph(pattern)
+	if err == ErrNoFreeGlyph {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	return byte(index), true, nil
+}
+
+// mapRune works like lookupRune, but returns '?' for runes that can't be
+// shown.
+func (lcd *Lcd) mapRune(r rune) (byte, error) {
+	code, ok, err := lcd.lookupRune(r)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return '?', nil
+	}
+	return code, nil
+}
+
+// SetGlyphFallback makes WriteRune write '?' for runes it can't show,
+// like WriteMapped does, instead of returning an error. Off by default.
+func (lcd *Lcd) SetGlyphFallback(enabled bool) {
+	lcd.glyphFallback = enabled
+}
+
+// WriteRune writes r at the current cursor position, translated to the
+// character ROM like WriteMapped does. Runes neither the ROM nor the
+// built-in font can show return an error, unless SetGlyphFallback is on.
+func (lcd *Lcd) WriteRune(r rune) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	code, ok, err := lcd.lookupRune(r)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if !lcd.glyphFallback {
+			return fmt.Errorf("Rune %q can't be shown with the character ROM "+
+				"or a CGRAM glyph", r)
+		}
+		code = '?'
+	}
+	return lcd.writeByte(code, PIN_RS)
+}
+
+// WriteMapped works like WriteAt, but translates text to the character
+// ROM (see WithCharROM) first, so e.g. "23.5°C" or "Grüße" show as
+// expected. Characters the ROM lacks are drawn from a small built-in font
+// into free CGRAM slots, and shown as '?' if that isn't possible.
+func (lcd *Lcd) WriteMapped(line, pos int, text string) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	// Upload all glyphs first, so the characters go out in one run
+	runes := []rune(text)
+	if w, _ := lcd.getSize(); w != -1 && pos >= 0 && pos < w && len(runes) > w-pos {
+		// Don't take glyph slots for characters cut off anyway
+		runes = runes[:w-pos]
+	}
+	for i, r := range runes {
+		code, err := lcd.mapRune(r)
+		if err != nil {
+			return err
+		}
+		runes[i] = rune(code)
+	}
+	return lcd.writeText(line, pos, string(runes))
+}
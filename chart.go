@@ -0,0 +1,327 @@
+package hd44780
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// verticalBar returns a glyph filled from the bottom up to height rows (1..8).
+func verticalBar(height int) [8]byte {
+	var pattern [8]byte
+	for row := 8 - height; row < 8; row++ {
+		pattern[row] = 0x1F
+	}
+	return pattern
+}
+
+// downsample reduces values to at most n points by averaging consecutive
+// buckets. Shorter series are returned unchanged.
+func downsample(values []float64, n int) []float64 {
+	if len(values) <= n {
+		return values
+	}
+	out := make([]float64, n)
+	for i := range out {
+		bucket := values[i*len(values)/n : (i+1)*len(values)/n]
+		var sum float64
+		for _, v := range bucket {
+			sum += v
+		}
+		out[i] = sum / float64(len(bucket))
+	}
+	return out
+}
+
+// DrawSparkline draws values as a trend of vertical bars over widthCols
+// cells of line, starting at startCol. Values are scaled between their
+// minimum and maximum to 8 bar heights, and a series longer than widthCols
+// is averaged down to fit. Unused cells and NaN values are left blank.
+// Full bars use the ROM's block character, the other bar heights a CGRAM
+// glyph each. DrawSparkline owns these slots and releases those the next
+// call doesn't need, so a sparkline of an earlier call may change. Bars
+// without a free slot are shown as a block if at least half full, and
+// blank otherwise.
+func (lcd *Lcd) DrawSparkline(line, startCol, widthCols int, values []float64) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	w, _ := lcd.getSize()
+	if widthCols < 1 || startCol < 0 || (w != -1 && startCol+widthCols > w) {
+		return fmt.Errorf("Sparkline of %d cells at position %d "+
+			"doesn't fit on the line", widthCols, startCol)
+	}
+	points := downsample(values, widthCols)
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, v := range points {
+		if !math.IsNaN(v) {
+			min = math.Min(min, v)
+			max = math.Max(max, v)
+		}
+	}
+
+	// Bar height per cell, 0 for blank ones
+	heights := make([]int, widthCols)
+	needed := make(map[[8]byte]bool)
+	for i := range heights {
+		if i >= len(points) || math.IsNaN(points[i]) {
+			continue
+		}
+		heights[i] = 1
+		if max > min {
+			heights[i] += int(math.Round((points[i] - min) / (max - min) * 7))
+		}
+		if heights[i] < 8 {
+			needed[verticalBar(heights[i])] = true
+		}
+	}
+	// Free the slots of bars no longer shown
+	if lcd.sparkSlots == nil {
+		lcd.sparkSlots = make(map[[8]byte]int)
+	}
+	for pattern, index := range lcd.sparkSlots {
+		if !needed[pattern] {
+			err := lcd.ReleaseGlyph(index)
+			if err != nil {
+				return err
+			}
+			delete(lcd.sparkSlots, pattern)
+		}
+	}
+
+	// Upload all glyphs first, so the characters go out in one run
+	codes := make([]byte, widthCols)
+	for i, height := range heights {
+		codes[i] = ' '
+		if height == 0 {
+			continue
+		}
+		if height == 8 {
+			codes[i] = romFullBlock
+			continue
+		}
+		pattern := verticalBar(height)
+		index, ok := lcd.sparkSlots[pattern]
+		if !ok {
+			var err error
+			index, err = lcd.allocGlyph(pattern)
+			if err == ErrNoFreeGlyph {
+				if height >= 4 {
+					codes[i] = romFullBlock
+				}
+				continue
+			} else if err != nil {
+				return err
+			}
+			lcd.sparkSlots[pattern] = index
+		}
+		codes[i] = byte(index)
+	}
+	err := lcd.SetPosition(line, startCol)
+	if err != nil {
+		return err
+	}
+	for i, c := range codes {
+		err = lcd.skipAddressGap(line, startCol+i)
+		if err != nil {
+			return err
+		}
+		err = lcd.writeByte(c, PIN_RS)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Full block character (all dots lit) of the character ROM.
+const romFullBlock = 0xFF
+
+// DrawProgressBar draws a bar of width cells at line, col, with the first
+// fraction (0..1) of it filled. Values outside that range are clamped.
+func (lcd *Lcd) DrawProgressBar(line, col, width int, fraction float64) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	err := lcd.checkRect(line, col, width, 1)
+	if err != nil {
+		return err
+	}
+	fraction = math.Max(0, math.Min(1, fraction))
+	filled := int(math.Round(fraction * float64(width)))
+	bar := make([]byte, width)
+	for i := range bar {
+		bar[i] = ' '
+		if i < filled {
+			bar[i] = romFullBlock
+		}
+	}
+	err = lcd.SetPosition(line, col)
+	if err != nil {
+		return err
+	}
+	for i, c := range bar {
+		err = lcd.skipAddressGap(line, col+i)
+		if err != nil {
+			return err
+		}
+		err = lcd.writeByte(c, PIN_RS)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gaugeText lays out "label: value unit" in exactly width characters,
+// with the value right-aligned. The label is shortened first if needed.
+func gaugeText(width int, label string, value float64, unit string) string {
+	right := []rune(strings.TrimSpace(fmt.Sprintf("%.1f %s", value, unit)))
+	if len(right) > width {
+		right = right[:width]
+	}
+	room := width - len(right)
+	left := label + ":"
+	if room < 1 {
+		return string(right)
+	}
+	if utf8.RuneCountInString(left) >= room {
+		left = fitWidth(left, room-1)
+	}
+	return fitWidth(left, room) + string(right)
+}
+
+// DrawGauge writes "label: value unit" across the whole line, with the
+// value shown to one decimal and right-aligned. A label too long for
+// the line is truncated.
+func (lcd *Lcd) DrawGauge(line int, label string, value float64, unit string) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	w, _ := lcd.getSize()
+	if w == -1 {
+		return errors.New("Can't lay out a gauge on a display with unknown size")
+	}
+	return lcd.writeText(line, 0, gaugeText(w, label, value, unit))
+}
+
+// DrawRangeGauge draws a gauge like DrawGauge on line, and below it a
+// full-width progress bar showing where value lies between min and max.
+func (lcd *Lcd) DrawRangeGauge(line int, label string, value, min, max float64, unit string) error {
+	err := lcd.DrawGauge(line, label, value, unit)
+	if err != nil {
+		return err
+	}
+	w, _ := lcd.getSize()
+	var fraction float64
+	if max > min {
+		fraction = (value - min) / (max - min)
+	}
+	return lcd.DrawProgressBar(line+1, 0, w, fraction)
+}
+
+// horizontalBar returns a glyph filled from the left up to cols columns (1..5).
+func horizontalBar(cols int) [8]byte {
+	var pattern [8]byte
+	for row := range pattern {
+		pattern[row] = 0x1F << uint(5-cols) & 0x1F
+	}
+	return pattern
+}
+
+// DrawBarGraph writes label at the start of line, followed by a bar over
+// the remaining columns filled to fraction (0..1, values outside are
+// clamped). Partially filled cells are drawn in steps of a fifth with CGRAM
+// glyphs, and left blank if no slot is free. A label too long to leave room
+// for the bar is truncated.
+func (lcd *Lcd) DrawBarGraph(line int, label string, fraction float64) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	w, _ := lcd.getSize()
+	if w == -1 {
+		return errors.New("Can't lay out a bar graph on a display with unknown size")
+	}
+	text := []rune(label)
+	if len(text) > 0 {
+		// Keep at least one cell for the bar, after a separating space
+		if max := w - 2; len(text) > max && max >= 0 {
+			text = text[:max]
+		}
+		text = append(text, ' ')
+	}
+	barWidth := w - len(text)
+
+	fraction = math.Max(0, math.Min(1, fraction))
+	filled := int(math.Round(fraction * float64(barWidth*5)))
+	partial := byte(' ')
+	if filled%5 != 0 {
+		index, err := lcd.RegisterGlyph(horizontalBar(filled % 5))
+		if err == nil {
+			partial = byte(index)
+		} else if err != ErrNoFreeGlyph {
+			return err
+		}
+	}
+	for i := 0; i < barWidth; i++ {
+		switch {
+		case i < filled/5:
+			text = append(text, romFullBlock)
+		case i == filled/5:
+			text = append(text, rune(partial))
+		default:
+			text = append(text, ' ')
+		}
+	}
+	return lcd.writeText(line, 0, string(text))
+}
+
+// temperatureWidth is the field width of WriteTemperature, fitting values
+// from -99.9 to 999.9 with the degree sign and unit.
+const temperatureWidth = 7
+
+// WriteTemperature writes celsius to one decimal followed by "°C" into a
+// right-aligned field of 7 characters at line, col, so changing values
+// don't leave stale characters behind. The degree sign is a CGRAM glyph
+// registered on first use, or the ROM's own one if no slot is free.
+func (lcd *Lcd) WriteTemperature(line, col int, celsius float64) error {
+	return lcd.writeTemperature(line, col, celsius, 'C')
+}
+
+// WriteTemperatureF works like WriteTemperature for a value in
+// fahrenheit, shown with "°F".
+func (lcd *Lcd) WriteTemperatureF(line, col int, fahrenheit float64) error {
+	return lcd.writeTemperature(line, col, fahrenheit, 'F')
+}
+
+func (lcd *Lcd) writeTemperature(line, col int, value float64, unit rune) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	text := fmt.Sprintf("%*.1f", temperatureWidth-2, value)
+	if len(text) > temperatureWidth-2 {
+		return fmt.Errorf("Temperature %s doesn't fit "+
+			"the %d character field", text, temperatureWidth)
+	}
+	degree, err := lcd.RegisterGlyph(Glyphs["degree"])
+	if err == ErrNoFreeGlyph {
+		code, _ := lcd.romChar('°')
+		degree = int(code)
+	} else if err != nil {
+		return err
+	}
+	return lcd.WriteField(line, col, temperatureWidth,
+		text+string(rune(degree))+string(unit))
+}
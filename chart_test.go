@@ -0,0 +1,50 @@
+package hd44780
+
+import "testing"
+
+func TestDrawSparklineFallback(t *testing.T) {
+	lcd, bus := newTestLcd(t, WithType(LCD_16x2))
+	// Something else holds all slots but one
+	for i := 0; i < CGRAM_Glyphs-1; i++ {
+		_, err := lcd.allocGlyph([8]byte{byte(i + 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	bus.reset()
+	err := lcd.DrawSparkline(0, 0, 8, []float64{0, 1, 2, 3, 4, 5, 6, 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := bus.sent()
+	var cells []byte
+	for _, s := range got[len(got)-8:] {
+		cells = append(cells, s.data)
+	}
+	want := []byte{CGRAM_Glyphs - 1, ' ', ' ', romFullBlock,
+		romFullBlock, romFullBlock, romFullBlock, romFullBlock}
+	if string(cells) != string(want) {
+		t.Errorf("DrawSparkline sent cells %v, want %v", cells, want)
+	}
+}
+
+func TestDrawSparklineReleasesSlots(t *testing.T) {
+	lcd, _ := newTestLcd(t, WithType(LCD_16x2))
+	err := lcd.DrawSparkline(0, 0, 8, []float64{0, 1, 2, 3, 4, 5, 6, 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lcd.DrawSparkline(0, 0, 8, []float64{1, 1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := 0
+	for _, u := range lcd.glyphUsed {
+		if u {
+			used++
+		}
+	}
+	if used != 1 {
+		t.Errorf("%d CGRAM slots in use after a flat sparkline, want 1", used)
+	}
+}
@@ -0,0 +1,53 @@
+package hd44780
+
+import "time"
+
+// ShowTime writes t formatted with layout (see time.Time.Format) from
+// the beginning of the specified line, truncated to the display width.
+func (lcd *Lcd) ShowTime(line int, t time.Time, layout string) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	return lcd.writeText(line, 0, t.Format(layout))
+}
+
+// StartClock shows the current time formatted with layout on the
+// specified line and updates it every second until stop is closed.
+// Only characters that changed since the previous update are rewritten.
+// StartClock blocks, so run it in its own goroutine.
+func (lcd *Lcd) StartClock(line int, layout string, stop <-chan struct{}) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	shown := []rune(time.Now().Format(layout))
+	err := lcd.writeText(line, 0, string(shown))
+	if err != nil {
+		return err
+	}
+	shutdown, done := lcd.startAnimation()
+	defer done()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-shutdown:
+			return lcd.inactiveErr()
+		case <-stop:
+			return nil
+		case t := <-ticker.C:
+			if !lcd.active.Load() {
+				return lcd.inactiveErr()
+			}
+			next := []rune(t.Format(layout))
+			err = lcd.writeDiff(line, 0, shown, next)
+			if err != nil {
+				return err
+			}
+			shown = next
+		}
+	}
+}
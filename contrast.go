@@ -0,0 +1,41 @@
+package hd44780
+
+import (
+	"errors"
+
+	"github.com/d2r2/go-i2c"
+)
+
+// ErrContrastUnsupported is returned by SetContrast on backpacks that set
+// the contrast with a trimpot.
+var ErrContrastUnsupported = errors.New("Backpack has no digital contrast control")
+
+// SetContrastDevice configures a digital potentiometer at its own I2C
+// address as the contrast control, written to at register by SetContrast.
+// Potentiometers on the expander's address are set via
+// PinMap.ContrastRegister instead.
+func (lcd *Lcd) SetContrastDevice(bus *i2c.I2C, register byte) {
+	lcd.contrastBus = nil
+	if bus != nil {
+		lcd.contrastBus = bus
+	}
+	lcd.contrastRegister = register
+}
+
+// SetContrast writes level to the contrast potentiometer. How levels map
+// to contrast depends on the part. ErrContrastUnsupported is returned if
+// no contrast control is configured.
+func (lcd *Lcd) SetContrast(level byte) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	if lcd.contrastBus == nil {
+		return ErrContrastUnsupported
+	}
+	lcd.busMutex.Lock()
+	defer lcd.busMutex.Unlock()
+	_, err := writeAll(lcd.contrastBus, []byte{lcd.contrastRegister, level})
+	return err
+}
@@ -0,0 +1,45 @@
+package hd44780
+
+import "fmt"
+
+// Counter shows a number right-aligned in a field of fixed width, e.g. an
+// odometer, and on every update only rewrites the digits that changed.
+type Counter struct {
+	lcd       *Lcd
+	line, col int
+	width     int
+	shown     []rune
+}
+
+// NewCounter creates a counter in the field of width characters at line,
+// col. Nothing is written until the first Set.
+func NewCounter(lcd *Lcd, line, col, width int) (*Counter, error) {
+	err := lcd.checkRect(line, col, width, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &Counter{lcd: lcd, line: line, col: col, width: width}, nil
+}
+
+// Set shows n in the counter's field. Numbers with more digits (and sign)
+// than the field is wide return an error and leave the field unchanged.
+func (c *Counter) Set(n int) error {
+	//Not active, so don't try do anything
+	if !c.lcd.active.Load() {
+		return c.lcd.inactiveErr()
+	}
+
+	text := []rune(fmt.Sprintf("%*d", c.width, n))
+	if len(text) > c.width {
+		return fmt.Errorf("Counter value %d doesn't fit "+
+			"the %d character field", n, c.width)
+	}
+	err := c.lcd.writeDiff(c.line, c.col, c.shown, text)
+	if err != nil {
+		// The field may be half written, so redraw it all next time
+		c.shown = nil
+		return err
+	}
+	c.shown = text
+	return nil
+}
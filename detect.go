@@ -0,0 +1,33 @@
+package hd44780
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/d2r2/go-i2c"
+)
+
+// EREMOTEIO on Linux. The i2c-dev driver reports a missing acknowledge with
+// either this or ENXIO, depending on the bus adapter.
+const errnoRemoteIO = syscall.Errno(121)
+
+// Detect probes whether a device acknowledges at the address bus was opened
+// with. It reads a single byte instead of writing, so the port expander
+// outputs (and with them the display contents and backlight) stay
+// untouched. A missing device is reported as false with a nil error; other
+// bus failures are returned as errors.
+func Detect(bus *i2c.I2C) (bool, error) {
+	if bus == nil {
+		return false, errors.New("I2C connection is nil")
+	}
+	_, err := bus.ReadBytes(make([]byte, 1))
+	if err != nil {
+		if errors.Is(err, syscall.ENXIO) || errors.Is(err, errnoRemoteIO) {
+			lg.Debugf("No device at address 0x%X: %v\n", bus.GetAddr(), err)
+			return false, nil
+		}
+		return false, fmt.Errorf("I2C read failed: %w", err)
+	}
+	return true, nil
+}
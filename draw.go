@@ -0,0 +1,53 @@
+package hd44780
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkRect returns an error unless a rectangle of w x h characters with
+// its top-left corner at line, col lies completely on the display.
+func (lcd *Lcd) checkRect(line, col, w, h int) error {
+	width, height := lcd.getSize()
+	if line < 0 || col < 0 || w < 1 || h < 1 ||
+		(width != -1 && col+w > width) || (height != -1 && line+h > height) {
+		return fmt.Errorf("Rectangle of %dx%d at line %d, position %d "+
+			"doesn't fit on the display", w, h, line, col)
+	}
+	return nil
+}
+
+// DrawBox draws the outline of a w x h character box with its top-left
+// corner at line, col, using '+' for the corners, '-' for the horizontal
+// and '|' for the vertical edges. The inside is left untouched. The box
+// must fit on the display and be at least 2x2.
+func (lcd *Lcd) DrawBox(line, col, w, h int) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	err := lcd.checkRect(line, col, w, h)
+	if err != nil {
+		return err
+	}
+	if w < 2 || h < 2 {
+		return fmt.Errorf("Box size %dx%d must be at least 2x2", w, h)
+	}
+	horizontal := "+" + strings.Repeat("-", w-2) + "+"
+	err = lcd.writeText(line, col, horizontal)
+	if err != nil {
+		return err
+	}
+	for row := line + 1; row < line+h-1; row++ {
+		err = lcd.writeCharAt(row, col, '|')
+		if err != nil {
+			return err
+		}
+		err = lcd.writeCharAt(row, col+w-1, '|')
+		if err != nil {
+			return err
+		}
+	}
+	return lcd.writeText(line+h-1, col, horizontal)
+}
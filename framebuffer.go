@@ -0,0 +1,235 @@
+package hd44780
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Framebuffer is an in-memory copy of the display contents. Drawing only
+// changes the copy, and Flush then sends just the characters that differ
+// from what the display shows, which keeps frequent updates cheap.
+type Framebuffer struct {
+	lcd           *Lcd
+	width, height int
+	cells         [][]byte
+	shown         [][]byte
+	dirty         [][]bool
+}
+
+// NewFramebuffer creates a blank framebuffer covering the whole display.
+// As the current display contents are unknown, the first Flush writes
+// every character.
+func NewFramebuffer(lcd *Lcd) (*Framebuffer, error) {
+	w, h := lcd.getSize()
+	if w == -1 {
+		return nil, errors.New("Can't create a framebuffer for a display with unknown size")
+	}
+	fb := &Framebuffer{lcd: lcd, width: w, height: h,
+		cells: make([][]byte, h),
+		shown: make([][]byte, h),
+		dirty: make([][]bool, h),
+	}
+	for line := range fb.cells {
+		fb.cells[line] = make([]byte, w)
+		fb.shown[line] = make([]byte, w)
+		fb.dirty[line] = make([]bool, w)
+		for col := range fb.cells[line] {
+			fb.cells[line][col] = ' '
+			fb.dirty[line][col] = true
+		}
+	}
+	return fb, nil
+}
+
+// contains reports whether line, col lies within the framebuffer.
+func (fb *Framebuffer) contains(line, col int) bool {
+	return line >= 0 && col >= 0 && line < fb.height && col < fb.width
+}
+
+// SetChar sets the character code at line, col. Positions outside the
+// display are ignored.
+func (fb *Framebuffer) SetChar(line, col int, c byte) {
+	if fb.contains(line, col) {
+		fb.cells[line][col] = c
+	}
+}
+
+// Char returns the character code at line, col, or 0 outside the display.
+func (fb *Framebuffer) Char(line, col int) byte {
+	if !fb.contains(line, col) {
+		return 0
+	}
+	return fb.cells[line][col]
+}
+
+// WriteAt puts text into the framebuffer starting at line, col, cut off
+// at the end of the line.
+func (fb *Framebuffer) WriteAt(line, col int, text string) {
+	for i, c := range []rune(text) {
+		fb.SetChar(line, col+i, byte(c))
+	}
+}
+
+// Clear fills the framebuffer with spaces.
+func (fb *Framebuffer) Clear() {
+	for _, row := range fb.cells {
+		for col := range row {
+			row[col] = ' '
+		}
+	}
+}
+
+// MarkDirty makes the next Flush write the character at line, col even if
+// it seems unchanged, e.g. after something else wrote to the display.
+func (fb *Framebuffer) MarkDirty(line, col int) {
+	if fb.contains(line, col) {
+		fb.dirty[line][col] = true
+	}
+}
+
+// Flush writes all characters that changed since the last flush, or were
+// marked dirty, to the display.
+func (fb *Framebuffer) Flush() error {
+	return fb.flush(0, 0, fb.width, fb.height, false)
+}
+
+// FlushRect writes the w x h characters with their top-left corner at
+// line, col to the display, without checking whether they changed.
+func (fb *Framebuffer) FlushRect(line, col, w, h int) error {
+	err := fb.lcd.checkRect(line, col, w, h)
+	if err != nil {
+		return err
+	}
+	return fb.flush(line, col, w, h, true)
+}
+
+// flush writes the changed characters of a region, or all of them if
+// force is set. Runs of neighbouring characters share one cursor move.
+func (fb *Framebuffer) flush(line, col, w, h int, force bool) error {
+	//Not active, so don't try do anything
+	if !fb.lcd.active.Load() {
+		return fb.lcd.inactiveErr()
+	}
+
+	for l := line; l < line+h; l++ {
+		next := -1 // Position the cursor is at, if it's on this line
+		for c := col; c < col+w; c++ {
+			if !force && !fb.dirty[l][c] && fb.cells[l][c] == fb.shown[l][c] {
+				continue
+			}
+			if c != next {
+				err := fb.lcd.SetPosition(l, c)
+				if err != nil {
+					return err
+				}
+			}
+			err := fb.lcd.skipAddressGap(l, c)
+			if err != nil {
+				return err
+			}
+			err = fb.lcd.writeByte(fb.cells[l][c], PIN_RS)
+			if err != nil {
+				return err
+			}
+			fb.shown[l][c] = fb.cells[l][c]
+			fb.dirty[l][c] = false
+			next = c + 1
+		}
+	}
+	return nil
+}
+
+// String renders the framebuffer contents as text in a box, e.g. for
+// logging what should be on the display. Characters outside of printable
+// ASCII, like CGRAM glyphs, are shown as '?'.
+func (fb *Framebuffer) String() string {
+	var b strings.Builder
+	border := "+" + strings.Repeat("-", fb.width) + "+\n"
+	b.WriteString(border)
+	for _, row := range fb.cells {
+		b.WriteByte('|')
+		for _, c := range row {
+			if c < ' ' || c > '~' {
+				c = '?'
+			}
+			b.WriteByte(c)
+		}
+		b.WriteString("|\n")
+	}
+	b.WriteString(border)
+	return b.String()
+}
+
+// Scale and colors of the images written by DumpPNG.
+const dumpDotSize = 4
+
+var (
+	dumpBackground = color.RGBA{0x20, 0x40, 0xC0, 0xFF}
+	dumpDotOff     = color.RGBA{0x30, 0x58, 0xD8, 0xFF}
+	dumpDotOn      = color.RGBA{0xE8, 0xF0, 0xFF, 0xFF}
+)
+
+// dumpUnknownChar stands in for characters DumpPNG has no bitmap of.
+var dumpUnknownChar = [8]byte{0x1F, 0x11, 0x11, 0x11, 0x11, 0x11, 0x1F, 0x00}
+
+// cellPattern returns the dots shown for character code c: a CGRAM glyph,
+// or an approximation of the ROM character from font5x7.
+func (fb *Framebuffer) cellPattern(c byte) [8]byte {
+	var pattern [8]byte
+	switch {
+	case c < 16:
+		// Codes 8..15 show the same glyphs as 0..7
+		if fb.lcd.glyphUsed[c%CGRAM_Glyphs] {
+			pattern = fb.lcd.glyphs[c%CGRAM_Glyphs]
+		}
+	case c == ' ':
+	default:
+		bitmap, ok := font5x7[unicode.ToUpper(rune(c))]
+		if !ok {
+			return dumpUnknownChar
+		}
+		copy(pattern[:], bitmap[:])
+	}
+	return pattern
+}
+
+// DumpPNG writes a PNG image of the framebuffer contents as the display
+// would show them, with every 5x8 dot cell drawn, for visual debugging.
+// Only digits, letters (lowercase drawn as uppercase), spaces and CGRAM
+// glyphs have bitmaps; other characters are drawn as a box.
+func (fb *Framebuffer) DumpPNG(w io.Writer) error {
+	// Cells are one dot apart, with a margin of one dot around them
+	img := image.NewRGBA(image.Rect(0, 0,
+		(fb.width*6+1)*dumpDotSize, (fb.height*9+1)*dumpDotSize))
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			img.Set(x, y, dumpBackground)
+		}
+	}
+	for line, row := range fb.cells {
+		for col, c := range row {
+			pattern := fb.cellPattern(c)
+			for dy, bits := range pattern {
+				for dx := 0; dx < 5; dx++ {
+					dot := dumpDotOff
+					if bits&(0x10>>uint(dx)) != 0 {
+						dot = dumpDotOn
+					}
+					x0 := (col*6 + 1 + dx) * dumpDotSize
+					y0 := (line*9 + 1 + dy) * dumpDotSize
+					for y := y0; y < y0+dumpDotSize-1; y++ {
+						for x := x0; x < x0+dumpDotSize-1; x++ {
+							img.Set(x, y, dot)
+						}
+					}
+				}
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
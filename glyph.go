@@ -0,0 +1,191 @@
+package hd44780
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// CGRAM_Glyphs is the number of custom characters (5x8 dots) the controller
+// can hold. They are shown by writing character codes 0..7.
+const CGRAM_Glyphs = 8
+
+// ErrNoFreeGlyph is returned by RegisterGlyph when all CGRAM slots are taken.
+var ErrNoFreeGlyph = errors.New("All CGRAM glyph slots are in use")
+
+// Glyphs holds ready-made patterns for symbols missing from the character
+// ROM, to be uploaded with e.g. lcd.RegisterGlyph(Glyphs["degree"]).
+var Glyphs = map[string][8]byte{
+	"degree":        {0x06, 0x09, 0x09, 0x06, 0x00, 0x00, 0x00, 0x00},
+	"celsius":       {0x18, 0x18, 0x03, 0x04, 0x04, 0x04, 0x03, 0x00},
+	"arrow-up":      {0x04, 0x0E, 0x15, 0x04, 0x04, 0x04, 0x04, 0x00},
+	"arrow-down":    {0x04, 0x04, 0x04, 0x04, 0x15, 0x0E, 0x04, 0x00},
+	"bell":          {0x04, 0x0E, 0x0E, 0x0E, 0x1F, 0x00, 0x04, 0x00},
+	"heart":         {0x00, 0x0A, 0x1F, 0x1F, 0x0E, 0x04, 0x00, 0x00},
+	"battery-empty": {0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x1F, 0x00},
+	"battery-half":  {0x0E, 0x11, 0x11, 0x11, 0x1F, 0x1F, 0x1F, 0x00},
+	"battery-full":  {0x0E, 0x1F, 0x1F, 0x1F, 0x1F, 0x1F, 0x1F, 0x00},
+	"wifi":          {0x00, 0x0E, 0x11, 0x04, 0x0A, 0x00, 0x04, 0x00},
+	"signal":        {0x00, 0x01, 0x01, 0x05, 0x05, 0x15, 0x15, 0x00},
+	"play":          {0x08, 0x0C, 0x0E, 0x0F, 0x0E, 0x0C, 0x08, 0x00},
+	"pause":         {0x00, 0x1B, 0x1B, 0x1B, 0x1B, 0x1B, 0x00, 0x00},
+}
+
+func checkGlyphIndex(index int) error {
+	if index < 0 || index > CGRAM_Glyphs-1 {
+		return fmt.Errorf("Glyph index %d "+
+			"must be within the range [0..%d]", index, CGRAM_Glyphs-1)
+	}
+	return nil
+}
+
+// DefineChar uploads a 5x8 dot pattern into CGRAM slot index (0..7).
+// Each byte is one row, top to bottom, and only its low 5 bits are used.
+// The cursor is put back where it was, so glyphs can be defined in the
+// middle of writing a line.
+func (lcd *Lcd) DefineChar(index int, pattern [8]byte) error {
+	err := checkGlyphIndex(index)
+	if err != nil {
+		return err
+	}
+	line, pos := lcd.cursorLine, lcd.cursorPos
+	err = lcd.writeByte(CMD_CGRAM_Set|byte(index<<3), 0)
+	if err != nil {
+		return err
+	}
+	for _, row := range pattern {
+		err = lcd.writeByte(row&0x1F, PIN_RS)
+		if err != nil {
+			return err
+		}
+	}
+	lcd.glyphs[index] = pattern
+	lcd.glyphUsed[index] = true
+	// Switch back to DDRAM, otherwise the next characters end up in CGRAM
+	return lcd.restoreCursor(line, pos)
+}
+
+// RegisterGlyph uploads pattern into the first free CGRAM slot and returns
+// the slot index, ready to be passed to WriteGlyph. If the same pattern is
+// already registered its slot is reused. ErrNoFreeGlyph is returned once
+// all 8 slots are taken.
+func (lcd *Lcd) RegisterGlyph(pattern [8]byte) (int, error) {
+	for i := 0; i < CGRAM_Glyphs; i++ {
+		if lcd.glyphUsed[i] && lcd.glyphs[i] == pattern {
+			return i, nil
+		}
+	}
+	return lcd.allocGlyph(pattern)
+}
+
+// allocGlyph uploads pattern into the first free CGRAM slot, without
+// sharing an existing slot, so the caller owns it exclusively.
+func (lcd *Lcd) allocGlyph(pattern [8]byte) (int, error) {
+	for i := 0; i < CGRAM_Glyphs; i++ {
+		if !lcd.glyphUsed[i] {
+			err := lcd.DefineChar(i, pattern)
+			if err != nil {
+				return -1, err
+			}
+			return i, nil
+		}
+	}
+	return -1, ErrNoFreeGlyph
+}
+
+// ReleaseGlyph marks a CGRAM slot as free for RegisterGlyph. Characters
+// already on screen keep showing the old pattern until the slot is reused.
+func (lcd *Lcd) ReleaseGlyph(index int) error {
+	err := checkGlyphIndex(index)
+	if err != nil {
+		return err
+	}
+	lcd.glyphUsed[index] = false
+	return nil
+}
+
+// WriteGlyph writes custom character index (0..7) at the current cursor position.
+func (lcd *Lcd) WriteGlyph(index int) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	err := checkGlyphIndex(index)
+	if err != nil {
+		return err
+	}
+	return lcd.writeByte(byte(index), PIN_RS)
+}
+
+// DefineCharFromImage uploads the top-left 5x8 pixels of img into CGRAM
+// slot index. Dark pixels (luminance below 50%) become lit dots, while
+// light and mostly transparent pixels stay off, so an icon drawn black on
+// white in a paint program appears as drawn.
+func (lcd *Lcd) DefineCharFromImage(index int, img image.Image) error {
+	b := img.Bounds()
+	if b.Dx() < 5 || b.Dy() < 8 {
+		return fmt.Errorf("Image of %dx%d pixels "+
+			"is smaller than the 5x8 character size", b.Dx(), b.Dy())
+	}
+	var pattern [8]byte
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 5; x++ {
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			_, _, _, a := c.RGBA()
+			gray := color.Gray16Model.Convert(c).(color.Gray16)
+			if a >= 0x8000 && gray.Y < 0x8000 {
+				pattern[y] |= 0x10 >> uint(x)
+			}
+		}
+	}
+	return lcd.DefineChar(index, pattern)
+}
+
+// jsonGlyph is one entry of the array read by LoadGlyphsJSON.
+type jsonGlyph struct {
+	Index int   `json:"index"`
+	Rows  []int `json:"rows"`
+}
+
+// LoadGlyphsJSON reads a JSON array of glyphs like
+//
+//	[{"index": 0, "rows": [0, 10, 31, 31, 14, 4, 0, 0]}]
+//
+// and uploads each into its CGRAM slot. Every glyph needs an index 0..7 and
+// 8 rows of 5-bit values (0..31), top to bottom. The whole input is checked
+// before anything is uploaded.
+func (lcd *Lcd) LoadGlyphsJSON(r io.Reader) error {
+	var glyphs []jsonGlyph
+	err := json.NewDecoder(r).Decode(&glyphs)
+	if err != nil {
+		return fmt.Errorf("Can't parse glyphs: %w", err)
+	}
+	patterns := make([][8]byte, len(glyphs))
+	for i, g := range glyphs {
+		err = checkGlyphIndex(g.Index)
+		if err != nil {
+			return err
+		}
+		if len(g.Rows) != 8 {
+			return fmt.Errorf("Glyph %d has %d rows instead of 8", g.Index, len(g.Rows))
+		}
+		for row, bits := range g.Rows {
+			if bits < 0 || bits > 0x1F {
+				return fmt.Errorf("Row %d of glyph %d is %d, "+
+					"must be within the range [0..31]", row, g.Index, bits)
+			}
+			patterns[i][row] = byte(bits)
+		}
+	}
+	for i, g := range glyphs {
+		err = lcd.DefineChar(g.Index, patterns[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
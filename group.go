@@ -0,0 +1,86 @@
+package hd44780
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DisplayGroup drives several displays, e.g. panels at different
+// addresses on the same I2C bus, either all together or one by one.
+type DisplayGroup struct {
+	displays []*Lcd
+}
+
+// GroupError is returned by DisplayGroup operations when any display
+// failed. Errors is indexed like the group, with nil for displays that
+// succeeded.
+type GroupError struct {
+	Errors []error
+}
+
+func (e *GroupError) Error() string {
+	var failed []string
+	for i, err := range e.Errors {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("display %d: %v", i, err))
+		}
+	}
+	return strings.Join(failed, "; ")
+}
+
+// Unwrap returns the errors of the displays that failed, so errors.Is and
+// errors.As look through a GroupError.
+func (e *GroupError) Unwrap() []error {
+	var failed []error
+	for _, err := range e.Errors {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return failed
+}
+
+// NewDisplayGroup creates a group of the specified displays.
+func NewDisplayGroup(displays ...*Lcd) *DisplayGroup {
+	return &DisplayGroup{displays: displays}
+}
+
+// Add appends lcd to the group and returns its index.
+func (g *DisplayGroup) Add(lcd *Lcd) int {
+	g.displays = append(g.displays, lcd)
+	return len(g.displays) - 1
+}
+
+// Len returns the number of displays in the group.
+func (g *DisplayGroup) Len() int {
+	return len(g.displays)
+}
+
+// Display returns the display at index i, to update a single panel.
+func (g *DisplayGroup) Display(i int) *Lcd {
+	return g.displays[i]
+}
+
+// Each calls fn for every display in the group. A failing display doesn't
+// stop the others; if any failed, the result is a *GroupError.
+func (g *DisplayGroup) Each(fn func(lcd *Lcd) error) error {
+	errs := make([]error, len(g.displays))
+	failed := false
+	for i, lcd := range g.displays {
+		errs[i] = fn(lcd)
+		if errs[i] != nil {
+			failed = true
+		}
+	}
+	if failed {
+		return &GroupError{Errors: errs}
+	}
+	return nil
+}
+
+// Broadcast shows text on every display of the group, see ShowMessage.
+func (g *DisplayGroup) Broadcast(text string, options ShowOptions) error {
+	return g.Each(func(lcd *Lcd) error {
+		return lcd.ShowMessage(text, options)
+	})
+}
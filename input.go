@@ -0,0 +1,66 @@
+package hd44780
+
+// InputField lets the user enter up to maxLen characters at line, col, with
+// the blinking cursor following the input. onChar is called for every
+// character and may block until one is typed: '\b' or DEL (0x7F) erases the
+// last character, '\n' or '\r' finishes the input, and other control
+// characters are ignored. The input also ends when stop is closed, checked
+// between characters. The entered text is returned and the cursor settings
+// are restored afterwards.
+func (lcd *Lcd) InputField(line, col, maxLen int, onChar func() rune,
+	stop <-chan struct{}) (string, error) {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return "", lcd.inactiveErr()
+	}
+
+	err := lcd.checkRect(line, col, maxLen, 1)
+	if err != nil {
+		return "", err
+	}
+	control := lcd.displayControl
+	defer func() {
+		lcd.displayControl = control
+		lcd.writeDisplayControl()
+	}()
+	err = lcd.BlinkOn()
+	if err != nil {
+		return "", err
+	}
+
+	var text []rune
+	for {
+		// The cursor stays on the last cell once the field is full
+		pos := len(text)
+		if pos == maxLen {
+			pos--
+		}
+		err = lcd.SetPosition(line, col+pos)
+		if err != nil {
+			return string(text), err
+		}
+		select {
+		case <-stop:
+			return string(text), nil
+		default:
+		}
+		r := onChar()
+		switch {
+		case r == '\n' || r == '\r':
+			return string(text), nil
+		case r == '\b' || r == 0x7F:
+			if len(text) > 0 {
+				text = text[:len(text)-1]
+				err = lcd.writeCharAt(line, col+len(text), ' ')
+			}
+		case r < ' ' || len(text) == maxLen:
+			// Ignore control characters and input beyond the field
+		default:
+			err = lcd.writeCharAt(line, col+len(text), byte(r))
+			text = append(text, r)
+		}
+		if err != nil {
+			return string(text), err
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package hd44780
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/d2r2/go-i2c"
 )
@@ -53,12 +57,32 @@ const (
 	PIN_RS        byte = 0x01 // Register select bit
 )
 
+// Default WRITE and RESET strobe delays in microseconds, see SetStrobeDelays.
+const (
+	defaultWriteStrobeDelay = 200
+	defaultResetStrobeDelay = 30
+)
+
+// Default data settle delay in microseconds, see SetSettleDelay.
+const defaultSettleDelay = 50
+
+// Default time SHOW_BACKLIGHT_PULSE keeps the backlight on, see
+// SetBacklightPulse.
+const defaultBacklightPulse = 5 * time.Second
+
 type LcdType int
 
 const (
 	LCD_UNKNOWN LcdType = iota
 	LCD_16x2
 	LCD_20x4
+	// 16x1 panels are wired as two 8 character halves, the right
+	// half living at the DDRAM address of a second line
+	LCD_16x1
+	LCD_40x1
+	// 40x4 panels have two controllers, one for the top and one for the
+	// bottom two lines, selected by separate enable pins (see PinMap.EN2)
+	LCD_40x4
 )
 
 type ShowOptions int
@@ -71,106 +95,314 @@ const (
 	SHOW_LINE_4
 	SHOW_ELIPSE_IF_NOT_FIT
 	SHOW_BLANK_PADDING
+	SHOW_ALIGN_CENTER
+	SHOW_ALIGN_RIGHT
+	SHOW_WORD_WRAP
+	// Turns the backlight on for the message, see SetBacklightPulse
+	SHOW_BACKLIGHT_PULSE
+	// Blanks the rest of every selected line, whether text reaches it or not
+	SHOW_CLEAR_LINES
+	// Continues the text after a '\n' on the next selected line
+	SHOW_NEWLINES
 )
 
+// Validate returns an error for options that make no sense for a display
+// of type lcdType: lines it doesn't have, a gap between the selected lines
+// (the lines in between would be written too), both alignments at once,
+// or bits that are no ShowOptions flag.
+func (o ShowOptions) Validate(lcdType LcdType) error {
+	all := ShowOptions(SHOW_LINE_1 | SHOW_LINE_2 | SHOW_LINE_3 | SHOW_LINE_4 |
+		SHOW_ELIPSE_IF_NOT_FIT | SHOW_BLANK_PADDING |
+		SHOW_ALIGN_CENTER | SHOW_ALIGN_RIGHT | SHOW_WORD_WRAP |
+		SHOW_BACKLIGHT_PULSE | SHOW_CLEAR_LINES | SHOW_NEWLINES)
+	if o&^all != 0 {
+		return fmt.Errorf("Unknown show options 0x%X", int(o&^all))
+	}
+	if o&SHOW_ALIGN_CENTER != 0 && o&SHOW_ALIGN_RIGHT != 0 {
+		return errors.New("SHOW_ALIGN_CENTER and SHOW_ALIGN_RIGHT can't be combined")
+	}
+	_, h := lcdType.size()
+	if h == -1 {
+		h = 4
+	}
+	lineFlags := []ShowOptions{SHOW_LINE_1, SHOW_LINE_2, SHOW_LINE_3, SHOW_LINE_4}
+	selected, gap := false, false
+	for i, flag := range lineFlags {
+		switch {
+		case o&flag == 0:
+			gap = gap || selected
+		case i >= h:
+			return fmt.Errorf("SHOW_LINE_%d is beyond "+
+				"the %d line(s) of the display", i+1, h)
+		case gap:
+			return errors.New("SHOW_LINE_* flags must select consecutive lines")
+		default:
+			selected = true
+		}
+	}
+	return nil
+}
+
+// ErrInactive is returned by output methods called after Shutdown,
+// once strict mode has been enabled with SetStrictInactive.
+var ErrInactive = errors.New("Display is not active")
+
+// ErrNotInitialized is returned by methods of an Lcd that wasn't created
+// with one of the constructors, e.g. a zero value Lcd{}.
+var ErrNotInitialized = errors.New("Display was not created with NewLcd")
+
+// i2cBus is the part of *i2c.I2C the display uses, so tests can stand in
+// a fake bus.
+type i2cBus interface {
+	WriteBytes(buf []byte) (int, error)
+	ReadBytes(buf []byte) (int, error)
+}
+
 type Lcd struct {
-	i2c              *i2c.I2C
+	i2c              i2cBus
+	initialized      bool
+	pins             PinMap
 	backlight        bool
+	backlightInvert  bool
 	lcdType          LcdType
 	writeStrobeDelay uint16
 	resetStrobeDelay uint16
-	active           bool
+	settleDelay      uint16
+	active           atomic.Bool // Read by the animation goroutines too
+	strictInactive   bool
 	displayFunction  byte
 	displayControl   byte
 	displayMode      byte
+	writeRetries     int
+	retryBackoff     time.Duration
+	minWriteInterval time.Duration
+	lastWrite        time.Time
+	buffered         bool
+	buffer           []byte
+	cursorLine       int
+	cursorPos        int
+	cgramMode        bool
+	controller       int
+	contrastBus      i2cBus
+	animationMutex   sync.Mutex
+	animationDone    chan struct{}
+	animations       *sync.WaitGroup
+	contrastRegister byte
+	width, height    int
+	lineAddress      func(line int) byte
+	charROM          CharROM
+	glyphFallback    bool
+	enable           byte
+	shift            int
+	tabWidth         int
+	writeNewlines    bool
+	asleep           bool
+	skipPowerOnDelay bool
+	keepContent      bool
+	nibbleSwap       bool
+	busMutex         sync.Mutex
+	pwmStop          chan struct{}
+	pwmDone          chan struct{}
+	pwmLevel         float64
+	wakeLevel        float64
+	initRetries      int
+	heartbeatFrame   int
+	heartbeatSlot    int
+	heartbeatOwned   bool
+	wakeBacklight    bool
+	backlightPulse   time.Duration
+	pulseTimer       *time.Timer
+	pulseRestore     bool
+	pulsing          bool
+	pulseGen         int
+	tallSlots        map[[8]byte]int
+	sparkSlots       map[[8]byte]int
+	logicalBytes     uint64
+	busWrites        uint64
+	glyphs           [CGRAM_Glyphs][8]byte
+	glyphUsed        [CGRAM_Glyphs]bool
 }
 
 func NewLcd(i2c *i2c.I2C, lcdType LcdType) (*Lcd, error) {
-	this := &Lcd{i2c: i2c,
-		backlight:        false,
-		lcdType:          lcdType,
-		writeStrobeDelay: 200,
-		resetStrobeDelay: 30,
-		active:           true,
-		displayFunction:  0x00,
-		displayControl:   0x00,
-		displayMode:      0x00,
+	pins := PINS_PCF8574
+	if lcdType == LCD_40x4 {
+		pins = PINS_PCF8574_40x4
+	}
+	return NewLcdWithOptions(i2c, WithType(lcdType), WithPins(pins))
+}
+
+// initialize runs the power-on initialization sequence, leaving the
+// display on, empty (unless WithoutInitialClear is set) and with the
+// cursor at home. With WithInitRetries the sequence is verified and
+// repeated until it takes.
+func (lcd *Lcd) initialize() error {
+	for attempt := 0; ; attempt++ {
+		err := lcd.initSequence(attempt == 0)
+		if err != nil {
+			return err
+		}
+		if lcd.initRetries == 0 {
+			break
+		}
+		ok, err := lcd.verifyInit()
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		if attempt == lcd.initRetries {
+			return fmt.Errorf("Display didn't respond after %d initialization attempts",
+				attempt+1)
+		}
+		lg.Debugf("Display initialization didn't take, retry %d of %d\n",
+			attempt+1, lcd.initRetries)
+	}
+
+	// Keep what's on the display, see WithoutInitialClear
+	if lcd.keepContent {
+		return nil
+	}
+
+	// Clear the display
+	err := lcd.Clear()
+	if err != nil {
+		return err
+	}
+
+	// Send cursor to home
+	err = lcd.Home()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// verifyInit checks that the controller took the initialization, by
+// writing a pattern to CGRAM slot 7 and reading it back, and restores the
+// slot afterwards. Without a way to read from the display (RW not wired
+// or grounded, or a register based expander) the function set is sent
+// once more instead, which can't fail.
+func (lcd *Lcd) verifyInit() (bool, error) {
+	if lcd.pins.RW == 0 || lcd.pins.Register != 0 {
+		return true, lcd.resendFunctionSet()
+	}
+	line, pos := lcd.cursorLine, lcd.cursorPos
+	err := lcd.waitBusy()
+	if err == ErrReadUnsupported {
+		return true, lcd.resendFunctionSet()
+	} else if err != nil {
+		return false, err
+	}
+	ok, err := lcd.strobeWorks(lcd.writeStrobeDelay)
+	if err != nil {
+		// Still try to put the slot back, the original error matters more
+		lcd.restoreCalibrationGlyph(line, pos)
+		return false, err
 	}
+	return ok, lcd.restoreCalibrationGlyph(line, pos)
+}
+
+// resendFunctionSet is verifyInit's fallback for displays that can't be
+// read from.
+func (lcd *Lcd) resendFunctionSet() error {
+	err := lcd.writeByte(CMD_Function_Set|lcd.displayFunction, 0)
+	time.Sleep(1 * time.Millisecond) // Wait 1ms	to be safe
+	return err
+}
 
+// initSequence sends the steps of the initialization sequence, from the
+// power-on delay (if first is set) up to the entry mode.
+func (lcd *Lcd) initSequence(first bool) error {
 	// Wait is required during initialization steps.  Various info below about delays.
 	// https://www.sparkfun.com/datasheets/LCD/HD44780.pdf (page 45)
 	// https://github.com/mrmorphic/hwio/blob/master/devices/hd44780/hd44780_i2c.go
 	// https://github.com/duinoWitchery/hd44780/blob/master/hd44780.cpp (read the comments)
 
 	// Initial delay as per datasheet (need at least 40ms after power rises above 2.7V before sending commands.)
-	time.Sleep(100 * time.Millisecond) // Wait 100ms vs 40ms
+	if first && !lcd.skipPowerOnDelay {
+		time.Sleep(100 * time.Millisecond) // Wait 100ms vs 40ms
+	}
 
 	// Step 1 -> Base initialization sent with safe minimum delay afterwards
-	var err = this.writeByte(0x03, 0)
+	var err = lcd.writeByte(0x03, 0)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	time.Sleep(5 * time.Millisecond) // Wait 5ms vs 4.1ms
 
 	// Step 2 -> Base initialization sent with safe minimum delay afterwards
-	err = this.writeByte(0x03, 0)
+	err = lcd.writeByte(0x03, 0)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	time.Sleep(1 * time.Millisecond) // Wait 1ms vs 100us
 
 	// Step 3 -> Base initialization sent with safe minimum delay afterwards
-	err = this.writeByte(0x03, 0)
+	err = lcd.writeByte(0x03, 0)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	time.Sleep(1 * time.Millisecond) // Wait 1ms vs 100us
 
 	// Step 4 -> 4-bit transfer mode sent with safe minimum delay afterwards
-	err = this.writeByte(0x02, 0)
+	err = lcd.writeByte(0x02, 0)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	time.Sleep(1 * time.Millisecond) // Wait 1ms vs 100us
 
 	// Step 5a -> Execute FUNCTIONSET command
-	this.displayFunction = OPT_2_Lines | OPT_5x8_Dots | OPT_4Bit_Mode
-	err = this.writeByte(CMD_Function_Set|this.displayFunction, 0)
+	lcd.displayFunction = OPT_2_Lines | OPT_5x8_Dots | OPT_4Bit_Mode
+	if _, h := lcd.getSize(); h == 1 && lcd.lcdType != LCD_16x1 {
+		// LCD_16x1 needs 2-line mode to address its right half
+		lcd.displayFunction = OPT_1_Lines | OPT_5x8_Dots | OPT_4Bit_Mode
+	}
+	err = lcd.writeByte(CMD_Function_Set|lcd.displayFunction, 0)
 	time.Sleep(1 * time.Millisecond) // Wait 1ms	to be safe
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// Step 5b -> Execute DISPLAYCONTROL command
-	this.displayControl = OPT_Enable_Display | OPT_Disable_Cursor | OPT_Disable_Blink
-	err = this.writeByte(CMD_Display_Control|this.displayControl, 0)
+	lcd.displayControl = OPT_Enable_Display | OPT_Disable_Cursor | OPT_Disable_Blink
+	err = lcd.writeByte(CMD_Display_Control|lcd.displayControl, 0)
 	time.Sleep(1 * time.Millisecond) // Wait 1ms	to be safe
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// Step 5c -> Execute ENTRYMODE command
-	this.displayMode = OPT_EntryLeft
-	err = this.writeByte(CMD_Entry_Mode|this.displayMode, 0)
+	lcd.displayMode = OPT_EntryLeft
+	err = lcd.writeByte(CMD_Entry_Mode|lcd.displayMode, 0)
 	time.Sleep(1 * time.Millisecond) // Wait 1ms	to be safe
 	if err != nil {
-		return nil, err
-
+		return err
 	}
 
-	// Clear the display
-	err = this.Clear()
-	if err != nil {
-		return nil, err
-	}
+	return nil
+}
 
-	// Send cursor to home
-	err = this.Home()
-	if err != nil {
-		return nil, err
+// NewLcdTimeout works like NewLcd, but returns an error if initialization
+// doesn't complete within timeout, e.g. because the I2C layer blocks on a
+// dead bus. An initialization that timed out is abandoned in the background.
+func NewLcdTimeout(i2c *i2c.I2C, lcdType LcdType, timeout time.Duration) (*Lcd, error) {
+	type result struct {
+		lcd *Lcd
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		lcd, err := NewLcd(i2c, lcdType)
+		done <- result{lcd, err}
+	}()
+	select {
+	case r := <-done:
+		return r.lcd, r.err
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("Display initialization timed out after %v", timeout)
 	}
-
-	return this, nil
 }
 
 type rawData struct {
@@ -178,9 +410,21 @@ type rawData struct {
 	Delay time.Duration
 }
 
+// maxBufferedWrite limits the size of a single I2C transfer issued by Flush,
+// to stay well within what common I2C adapters accept in one message.
+const maxBufferedWrite = 96
+
 func (lcd *Lcd) writeRawDataSeq(seq []rawData) error {
+	if lcd.buffered {
+		// Delays are dropped: at I2C clock speeds each byte already takes
+		// longer to transfer than the strobe timing requires.
+		for _, item := range seq {
+			lcd.buffer = append(lcd.buffer, lcd.output(item.Data))
+		}
+		return nil
+	}
 	for _, item := range seq {
-		_, err := lcd.i2c.WriteBytes([]byte{item.Data})
+		err := lcd.writeBus([]byte{lcd.output(item.Data)})
 		if err != nil {
 			return err
 		}
@@ -189,27 +433,185 @@ func (lcd *Lcd) writeRawDataSeq(seq []rawData) error {
 	return nil
 }
 
-func (lcd *Lcd) writeDataWithStrobe(data byte) error {
-	if lcd.backlight {
-		data |= PIN_BACKLIGHT
+// writeBus sends buf in one I2C transaction, retrying a failed
+// write as configured with SetWriteRetries. The caller must hold busMutex,
+// so transfers never interleave with SetBacklightLevel's.
+func (lcd *Lcd) writeBus(buf []byte) error {
+	if !lcd.initialized {
+		return ErrNotInitialized
+	}
+	n, err := lcd.writeTransaction(buf)
+	for i := 0; err != nil && i < lcd.writeRetries; i++ {
+		// Bytes that got through were strobed already, sending them
+		// again would put the nibbles out of step
+		buf = buf[n:]
+		lg.Debugf("I2C write failed, retry %d of %d: %v\n", i+1, lcd.writeRetries, err)
+		time.Sleep(lcd.retryBackoff)
+		n, err = lcd.writeTransaction(buf)
+	}
+	return err
+}
+
+// writeTransaction writes buf to the bus, after the expander register if
+// the PinMap has one, and returns how many bytes of buf got through.
+func (lcd *Lcd) writeTransaction(buf []byte) (int, error) {
+	if lcd.pins.Register != 0 {
+		buf = append([]byte{lcd.pins.Register}, buf...)
+	}
+	lcd.busWrites++
+	n, err := writeAll(lcd.i2c, buf)
+	if lcd.pins.Register != 0 && n > 0 {
+		n--
+	}
+	return n, err
+}
+
+// Stats returns how many bytes (characters and commands) were sent to the
+// display, and in how many I2C write transactions, retries included, since
+// it was created. Comparing both shows the effect of buffered writes or a
+// Framebuffer on bus traffic.
+func (lcd *Lcd) Stats() (logicalBytes, busWrites uint64) {
+	lcd.busMutex.Lock()
+	defer lcd.busMutex.Unlock()
+	return lcd.logicalBytes, lcd.busWrites
+}
+
+// writeAll writes buf to bus, treating a short write as an error. It
+// returns how many bytes were written either way.
+func writeAll(bus i2cBus, buf []byte) (int, error) {
+	n, err := bus.WriteBytes(buf)
+	if n < 0 {
+		n = 0
+	} else if n > len(buf) {
+		n = len(buf)
+	}
+	if err != nil {
+		return n, fmt.Errorf("I2C write failed: %w", err)
+	}
+	if n < len(buf) {
+		return n, fmt.Errorf("Short I2C write: "+
+			"%d of %d bytes written", n, len(buf))
+	}
+	return n, nil
+}
+
+// SetWriteRetries sets how many times a failed I2C write is retried, waiting
+// backoff before each retry. The default of zero retries returns the first
+// error straight away.
+func (lcd *Lcd) SetWriteRetries(count int, backoff time.Duration) {
+	lcd.writeRetries = count
+	lcd.retryBackoff = backoff
+}
+
+// SetMinWriteInterval enforces a minimum gap between consecutive bytes sent
+// to the display, for panels that drop characters when written to in a
+// tight loop. Zero, the default, disables the limit. While it's set,
+// buffered writes are flushed before every character or command, so each
+// goes out on its own as the 6 bus bytes of its two 4-bit transfers.
+func (lcd *Lcd) SetMinWriteInterval(d time.Duration) {
+	lcd.minWriteInterval = d
+}
+
+// SetBufferedWrites enables or disables buffered mode. In buffered mode bus
+// bytes are queued instead of being sent one per I2C transaction, and go
+// out in larger transfers on Flush. Clear and Home flush automatically,
+// since the controller needs time to execute them. Disabling buffered mode
+// flushes anything still queued.
+func (lcd *Lcd) SetBufferedWrites(enabled bool) error {
+	var err error
+	if !enabled {
+		err = lcd.Flush()
+	}
+	lcd.buffered = enabled
+	return err
+}
+
+// Flush sends all bytes queued in buffered mode to the display. It does
+// nothing when buffered mode is off or the queue is empty.
+func (lcd *Lcd) Flush() error {
+	lcd.busMutex.Lock()
+	defer lcd.busMutex.Unlock()
+	return lcd.flush()
+}
+
+// flush works like Flush, for callers already holding busMutex.
+func (lcd *Lcd) flush() error {
+	for len(lcd.buffer) > 0 {
+		n := len(lcd.buffer)
+		if n > maxBufferedWrite {
+			n = maxBufferedWrite
+		}
+		err := lcd.writeBus(lcd.buffer[:n])
+		if err != nil {
+			lcd.buffer = lcd.buffer[:0]
+			return err
+		}
+		lcd.buffer = lcd.buffer[n:]
 	}
+	lcd.buffer = nil
+	return nil
+}
+
+// withBacklight sets or clears PIN_BACKLIGHT in data to match the backlight state.
+func (lcd *Lcd) withBacklight(data byte) byte {
+	// The backlight pin is driven low for "on" on backpacks with inverted polarity
+	if lcd.backlight != lcd.backlightInvert {
+		return data | PIN_BACKLIGHT
+	}
+	return data &^ PIN_BACKLIGHT
+}
+
+func (lcd *Lcd) writeDataWithStrobe(data byte) error {
+	data = lcd.withBacklight(data)
 	seq := []rawData{
-		{data, 50 * 1000 * time.Nanosecond},                                     // send data
+		{data, time.Duration(lcd.settleDelay) * time.Microsecond},               // send data
 		{data | PIN_EN, time.Duration(lcd.writeStrobeDelay) * time.Microsecond}, // set strobe
 		{data, time.Duration(lcd.resetStrobeDelay) * time.Microsecond},          // reset strobe
 	}
 	return lcd.writeRawDataSeq(seq)
 }
 
+// throttle waits until at least minWriteInterval has passed since the
+// previous byte write. Buffered bytes are flushed first, as the gap would
+// otherwise be lost in the bulk transfer.
+func (lcd *Lcd) throttle() error {
+	if lcd.minWriteInterval <= 0 {
+		return nil
+	}
+	err := lcd.flush()
+	if err != nil {
+		return err
+	}
+	if wait := lcd.minWriteInterval - time.Since(lcd.lastWrite); wait > 0 {
+		time.Sleep(wait)
+	}
+	lcd.lastWrite = time.Now()
+	return nil
+}
+
 func (lcd *Lcd) writeByte(data byte, controlPins byte) error {
-	err := lcd.writeDataWithStrobe(data&0xF0 | controlPins)
+	// Keep SetBacklightLevel from writing between the strobes
+	lcd.busMutex.Lock()
+	defer lcd.busMutex.Unlock()
+	err := lcd.throttle()
 	if err != nil {
 		return err
 	}
-	err = lcd.writeDataWithStrobe((data<<4)&0xF0 | controlPins)
+	lcd.enable = lcd.enableMask(data, controlPins)
+	wire := data
+	if lcd.nibbleSwap {
+		wire = reverseNibbles(data)
+	}
+	err = lcd.writeDataWithStrobe(wire&0xF0 | controlPins)
+	if err != nil {
+		return err
+	}
+	err = lcd.writeDataWithStrobe((wire<<4)&0xF0 | controlPins)
 	if err != nil {
 		return err
 	}
+	lcd.logicalBytes++
+	lcd.trackCursor(data, controlPins)
 	return nil
 }
 
@@ -233,6 +635,10 @@ func (lcd *Lcd) getLineRange(options ShowOptions) (startLine, endLine int) {
 			break
 		}
 	}
+	// Without any SHOW_LINE_* flag output goes to the first line
+	if startLine == -1 {
+		return 0, 0
+	}
 	return startLine, endLine
 }
 
@@ -240,157 +646,744 @@ func (lcd *Lcd) splitText(text string, options ShowOptions) []string {
 	var lines []string
 	startLine, endLine := lcd.getLineRange(options)
 	w, _ := lcd.getSize()
+	runes := []rune(text)
 	if w != -1 && startLine != -1 && endLine != -1 {
 		for i := 0; i <= endLine-startLine; i++ {
-			if len(text) == 0 {
+			if len(runes) == 0 {
 				break
 			}
-			j := w
-			if j > len(text) {
-				j = len(text)
-			}
-			lines = append(lines, text[:j])
-			text = text[j:]
+			var line []rune
+			line, runes = lcd.cutLine(runes, w, options)
+			lines = append(lines, string(line))
 		}
-		if len(text) > 0 {
+		if len(runes) > 0 && len(lines) > 0 {
 			if options&SHOW_ELIPSE_IF_NOT_FIT != 0 {
+				// Mark the cut with "~", replacing the last character of a
+				// full line. On a 1 character wide display only "~" is left.
 				j := len(lines) - 1
-				lines[j] = lines[j][:len(lines[j])-1] + "~"
+				last := []rune(lines[j])
+				if len(last) >= w {
+					last = lcd.trimForEllipsis(last, w, options)
+				}
+				lines[j] = string(last) + "~"
 			}
-		} else {
+		}
+		for i := range lines {
+			lines[i] = lcd.alignLine(lines[i], w, options)
+		}
+		if len(runes) == 0 {
 			if options&SHOW_BLANK_PADDING != 0 {
-				j := len(lines) - 1
-				lines[j] = lines[j] + strings.Repeat(" ", w-len(lines[j]))
-				for k := j + 1; k <= endLine-startLine; k++ {
+				for j := range lines {
+					lines[j] = lines[j] + strings.Repeat(" ", w-utf8.RuneCountInString(lines[j]))
+				}
+				for k := len(lines); k <= endLine-startLine; k++ {
 					lines = append(lines, strings.Repeat(" ", w))
 				}
 			}
 
 		}
-	} else if len(text) > 0 {
+		if options&SHOW_CLEAR_LINES != 0 {
+			for j := range lines {
+				lines[j] = fitWidth(lines[j], w)
+			}
+			for k := len(lines); k <= endLine-startLine; k++ {
+				lines = append(lines, strings.Repeat(" ", w))
+			}
+		}
+	} else if len(runes) > 0 {
 		lines = append(lines, text)
 	}
 	return lines
 }
 
+// cutLine takes the next display line of at most width characters from
+// text and returns it together with the remaining text. With SHOW_WORD_WRAP
+// the line is broken at the last space that fits, and the space itself is
+// dropped; words longer than width are still split hard. With SHOW_NEWLINES
+// a '\n' that comes up first ends the line, and is dropped as well.
+func (lcd *Lcd) cutLine(text []rune, width int, options ShowOptions) (line, rest []rune) {
+	if options&SHOW_NEWLINES != 0 {
+		for k := 0; k <= width && k < len(text); k++ {
+			if text[k] == '\n' {
+				return text[:k], text[k+1:]
+			}
+		}
+	}
+	if len(text) <= width {
+		return text, nil
+	}
+	if options&SHOW_WORD_WRAP != 0 {
+		for k := width; k > 0; k-- {
+			if text[k] != ' ' {
+				continue
+			}
+			line, rest = text[:k], text[k:]
+			for len(line) > 0 && line[len(line)-1] == ' ' {
+				line = line[:len(line)-1]
+			}
+			for len(rest) > 0 && rest[0] == ' ' {
+				rest = rest[1:]
+			}
+			return line, rest
+		}
+	}
+	return text[:width], text[width:]
+}
+
+// trimForEllipsis shortens a full line to make room for the "~" marking
+// that text was cut off. With SHOW_WORD_WRAP the word that doesn't fit
+// anymore is dropped as a whole, unless it's the only one on the line.
+func (lcd *Lcd) trimForEllipsis(line []rune, width int, options ShowOptions) []rune {
+	line = line[:width-1]
+	if options&SHOW_WORD_WRAP == 0 {
+		return line
+	}
+	for k := len(line) - 1; k > 0; k-- {
+		if line[k] != ' ' {
+			continue
+		}
+		for k > 0 && line[k-1] == ' ' {
+			k--
+		}
+		if k > 0 {
+			return line[:k]
+		}
+	}
+	return line
+}
+
+// alignLine pads line up to width with leading and/or trailing spaces
+// according to SHOW_ALIGN_CENTER or SHOW_ALIGN_RIGHT. Left alignment is
+// the default, so line is returned untouched when neither flag is set.
+func (lcd *Lcd) alignLine(line string, width int, options ShowOptions) string {
+	gap := width - utf8.RuneCountInString(line)
+	if gap <= 0 {
+		return line
+	}
+	switch {
+	case options&SHOW_ALIGN_CENTER != 0:
+		left := gap / 2
+		return strings.Repeat(" ", left) + line + strings.Repeat(" ", gap-left)
+	case options&SHOW_ALIGN_RIGHT != 0:
+		return strings.Repeat(" ", gap) + line
+	default:
+		return line
+	}
+}
+
+// ShowMessage writes text to the lines selected with the SHOW_LINE_* flags,
+// wrapping it from one line to the next as needed. Without any SHOW_LINE_*
+// flag the text goes to the first line, as with SHOW_LINE_1. The other
+// flags control alignment, wrapping, padding and overflow. If a write
+// fails partway, the cursor is sent home (as far as still possible) before
+// the error is returned, so the next call starts from a known position.
 func (lcd *Lcd) ShowMessage(text string, options ShowOptions) error {
 	//Not active, so don't try do anything
-	if !lcd.active {
-		return nil
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
 	}
 
 	lines := lcd.splitText(text, options)
 	lg.Debugf("Output: %v\n", lines)
-	startLine, endLine := lcd.getLineRange(options)
-	i := 0
-	for {
-		if startLine != -1 && endLine != -1 {
-			err := lcd.SetPosition(i+startLine, 0)
+	// Nothing to show, e.g. for an empty text without SHOW_BLANK_PADDING
+	if len(lines) == 0 {
+		return nil
+	}
+	if options&SHOW_BACKLIGHT_PULSE != 0 {
+		err := lcd.pulseBacklight()
+		if err != nil {
+			return err
+		}
+	}
+	startLine, _ := lcd.getLineRange(options)
+	err := lcd.showLines(lines, startLine)
+	if err != nil {
+		// Best effort to leave the cursor at a known position
+		lcd.Home()
+	}
+	return err
+}
+
+// ShowLines writes lines to consecutive display lines from startLine on,
+// without any of ShowMessage's wrapping. Each line is truncated or padded
+// with spaces to the display width, so nothing of the previous content is
+// left on those lines. Like ShowMessage it homes the cursor on errors.
+func (lcd *Lcd) ShowLines(lines []string, startLine int) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	w, _ := lcd.getSize()
+	if w == -1 {
+		return errors.New("Can't fit lines to a display with unknown size")
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	err := lcd.checkRect(startLine, 0, w, len(lines))
+	if err != nil {
+		return err
+	}
+	padded := make([]string, len(lines))
+	for i, line := range lines {
+		padded[i] = fitWidth(line, w)
+	}
+	err = lcd.showLines(padded, startLine)
+	if err != nil {
+		// Best effort to leave the cursor at a known position
+		lcd.Home()
+	}
+	return err
+}
+
+// showLines writes lines to consecutive display lines from startLine on.
+func (lcd *Lcd) showLines(lines []string, startLine int) error {
+	for i, line := range lines {
+		err := lcd.SetPosition(i+startLine, 0)
+		if err != nil {
+			return err
+		}
+		for j, c := range []rune(line) {
+			err = lcd.skipAddressGap(i+startLine, j)
 			if err != nil {
 				return err
 			}
-		}
-		line := lines[i]
-		for _, c := range line {
-			err := lcd.writeByte(byte(c), PIN_RS)
+			err = lcd.writeByte(byte(c), PIN_RS)
 			if err != nil {
 				return err
 			}
 		}
-		if i == len(lines)-1 {
-			break
-		}
-		i++
 	}
 	return nil
 }
 
-func (lcd *Lcd) TestWriteCGRam() error {
-	err := lcd.writeByte(CMD_CGRAM_Set, 0)
+// writeText writes text starting at the specified position,
+// cutting it off at the end of the line.
+func (lcd *Lcd) writeText(line, pos int, text string) error {
+	err := lcd.SetPosition(line, pos)
 	if err != nil {
 		return err
 	}
-	var a byte = 0x55
-	for i := 0; i < 80; i++ {
-		err := lcd.writeByte(a, PIN_RS)
+	w, _ := lcd.getSize()
+	for i, c := range []rune(text) {
+		if w != -1 && pos+i >= w {
+			break
+		}
+		err = lcd.skipAddressGap(line, pos+i)
+		if err != nil {
+			return err
+		}
+		err = lcd.writeByte(byte(c), PIN_RS)
 		if err != nil {
 			return err
 		}
-		a = a ^ 0xFF
 	}
 	return nil
 }
 
-func (lcd *Lcd) BacklightOn() error {
-	lcd.backlight = true
-	err := lcd.writeByte(0x00, 0)
-	if err != nil {
-		return err
+// fitWidth truncates or space-pads text to exactly width characters.
+func fitWidth(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) >= width {
+		return string(runes[:width])
 	}
-	return nil
+	return text + strings.Repeat(" ", width-len(runes))
 }
 
-func (lcd *Lcd) BacklightOff() error {
-	lcd.backlight = false
-	err := lcd.writeByte(0x00, 0)
-	if err != nil {
-		return err
+// writeDiff updates text previously written as old at the specified
+// position to new, rewriting only the characters that differ. Characters
+// of old past the length of new are blanked.
+func (lcd *Lcd) writeDiff(line, pos int, old, new []rune) error {
+	w, _ := lcd.getSize()
+	n := len(new)
+	if len(old) > n {
+		n = len(old)
+	}
+	last := -2
+	for i := 0; i < n; i++ {
+		if w != -1 && pos+i >= w {
+			break
+		}
+		c := ' '
+		if i < len(new) {
+			c = new[i]
+		}
+		if i < len(old) && old[i] == c {
+			continue
+		}
+		var err error
+		// Reposition at the start of each run of changed characters
+		if i != last+1 {
+			err = lcd.SetPosition(line, pos+i)
+		} else {
+			err = lcd.skipAddressGap(line, pos+i)
+		}
+		if err != nil {
+			return err
+		}
+		err = lcd.writeByte(byte(c), PIN_RS)
+		if err != nil {
+			return err
+		}
+		last = i
 	}
 	return nil
 }
 
-func (lcd *Lcd) Clear() error {
-	err := lcd.writeByte(CMD_Clear_Display, 0)
-	time.Sleep(2 * time.Millisecond) // Do same delay as Home().
-	return err
-}
+// WriteAt writes text starting at the specified line and position,
+// truncated at the end of the line.
+func (lcd *Lcd) WriteAt(line, pos int, text string) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
 
-func (lcd *Lcd) Home() error {
-	err := lcd.writeByte(CMD_Return_Home, 0)
-	time.Sleep(2 * time.Millisecond) // Page 24 of datasheet says 1.52ms to execute.  We will do slightly longer delay.
-	return err
+	return lcd.writeText(line, pos, text)
 }
 
-func (lcd *Lcd) DisplayOn() error {
-	lcd.displayControl |= OPT_Enable_Display
-	err := lcd.writeByte(CMD_Display_Control|lcd.displayControl, 0)
-	time.Sleep(2 * time.Millisecond) // Do same delay as Home().
-	return err
-}
+// Overwrite works like WriteAt, but moves the cursor back to line, col
+// afterwards, so a blinking cursor or the next write stays on the start
+// of the field that's updated over and over.
+func (lcd *Lcd) Overwrite(line, col int, text string) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	err := lcd.writeText(line, col, text)
+	if err != nil {
+		return err
+	}
+	return lcd.SetPosition(line, col)
+}
+
+// WriteField writes text into a field of exactly width characters at line,
+// col, padded with spaces or truncated as needed. Unlike WriteAt this never
+// leaves stale characters behind when a shorter text, e.g. "9%" after
+// "100%", replaces a longer one.
+func (lcd *Lcd) WriteField(line, col, width int, text string) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	err := lcd.checkRect(line, col, width, 1)
+	if err != nil {
+		return err
+	}
+	return lcd.writeText(line, col, fitWidth(text, width))
+}
+
+// WriteGrid writes grid to the display, row i to line i, usually to update
+// the whole screen at once. Rows shorter than the display are padded with
+// spaces and parts of the grid beyond the display are ignored. Lines
+// without a row are left untouched.
+func (lcd *Lcd) WriteGrid(grid [][]rune) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	w, h := lcd.getSize()
+	if w == -1 {
+		return errors.New("Can't write a grid to a display with unknown size")
+	}
+	for line, row := range grid {
+		if line >= h {
+			break
+		}
+		err := lcd.writeText(line, 0, fitWidth(string(row), w))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCentered writes text centered on the specified line, truncated if
+// it's longer than the line. The rest of the line is left untouched.
+func (lcd *Lcd) WriteCentered(line int, text string) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	w, _ := lcd.getSize()
+	if w == -1 {
+		return errors.New("Can't center text on a display with unknown size")
+	}
+	pos := (w - utf8.RuneCountInString(text)) / 2
+	if pos < 0 {
+		pos = 0
+	}
+	return lcd.writeText(line, pos, text)
+}
+
+// Printf formats according to format and writes the result from the
+// beginning of the specified line, truncated to the display width.
+func (lcd *Lcd) Printf(line int, format string, args ...interface{}) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	return lcd.writeText(line, 0, fmt.Sprintf(format, args...))
+}
+
+// TypewriterEffect writes text to the specified line one character at a
+// time, sleeping charDelay after each character. Output stops at the end
+// of the line.
+func (lcd *Lcd) TypewriterEffect(line int, text string, charDelay time.Duration) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	err := lcd.SetPosition(line, 0)
+	if err != nil {
+		return err
+	}
+	w, _ := lcd.getSize()
+	for i, c := range []rune(text) {
+		// Stop at the line boundary, or when Shutdown was called meanwhile
+		if w != -1 && i >= w {
+			break
+		}
+		if !lcd.active.Load() {
+			return lcd.inactiveErr()
+		}
+		err = lcd.skipAddressGap(line, i)
+		if err != nil {
+			return err
+		}
+		err = lcd.writeByte(byte(c), PIN_RS)
+		if err != nil {
+			return err
+		}
+		time.Sleep(charDelay)
+	}
+	return nil
+}
+
+func (lcd *Lcd) TestWriteCGRam() error {
+	err := lcd.writeByte(CMD_CGRAM_Set, 0)
+	if err != nil {
+		return err
+	}
+	var a byte = 0x55
+	for i := 0; i < 80; i++ {
+		err := lcd.writeByte(a, PIN_RS)
+		if err != nil {
+			return err
+		}
+		a = a ^ 0xFF
+	}
+	return nil
+}
+
+func (lcd *Lcd) BacklightOn() error {
+	lcd.stopBacklightPWM()
+	lcd.cancelBacklightPulse()
+	lcd.setBacklightState(true)
+	err := lcd.writeByte(0x00, 0)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (lcd *Lcd) BacklightOff() error {
+	lcd.stopBacklightPWM()
+	lcd.cancelBacklightPulse()
+	lcd.setBacklightState(false)
+	err := lcd.writeByte(0x00, 0)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetBacklightPolarity selects how PIN_BACKLIGHT is driven. Most backpacks
+// are active high (the default); pass false for clones whose backlight
+// transistor turns on when the pin is low.
+func (lcd *Lcd) SetBacklightPolarity(activeHigh bool) {
+	lcd.backlightInvert = !activeHigh
+}
+
+// setBacklightState changes the backlight state the next write uses. It
+// holds busMutex, as the timer of pulseBacklight changes it as well.
+func (lcd *Lcd) setBacklightState(on bool) {
+	lcd.busMutex.Lock()
+	defer lcd.busMutex.Unlock()
+	lcd.backlight = on
+}
+
+// backlightState returns the backlight state, see setBacklightState.
+func (lcd *Lcd) backlightState() bool {
+	lcd.busMutex.Lock()
+	defer lcd.busMutex.Unlock()
+	return lcd.backlight
+}
+
+func (lcd *Lcd) setBacklight(on bool) error {
+	if on {
+		return lcd.BacklightOn()
+	}
+	return lcd.BacklightOff()
+}
+
+// SetBacklightPulse sets how long ShowMessage with SHOW_BACKLIGHT_PULSE
+// keeps the backlight on before putting it back into its previous state.
+// The default is 5 seconds. The backlight is restored from a timer
+// goroutine, like the animations in the background of other output.
+func (lcd *Lcd) SetBacklightPulse(d time.Duration) {
+	lcd.backlightPulse = d
+}
+
+// pulseBacklight turns the backlight on and restores its previous state
+// after the pulse time from a timer. Another pulse before that extends
+// the current one, so the state before the first is restored. If the
+// display sleeps by then, the state is restored on Wake instead.
+func (lcd *Lcd) pulseBacklight() error {
+	lcd.busMutex.Lock()
+	if !lcd.pulsing {
+		lcd.pulseRestore = lcd.backlight
+	}
+	restore := lcd.pulseRestore
+	lcd.busMutex.Unlock()
+	// This cancels the pending pulse, if any
+	err := lcd.BacklightOn()
+	if err != nil {
+		return err
+	}
+	lcd.busMutex.Lock()
+	lcd.pulsing = true
+	gen := lcd.pulseGen
+	lcd.busMutex.Unlock()
+	lcd.pulseTimer = time.AfterFunc(lcd.backlightPulse, func() {
+		lcd.busMutex.Lock()
+		defer lcd.busMutex.Unlock()
+		if !lcd.active.Load() || lcd.pulseGen != gen {
+			return
+		}
+		lcd.pulsing = false
+		if lcd.asleep {
+			lcd.wakeBacklight = restore
+			return
+		}
+		lcd.backlight = restore
+		// Buffered bytes carry the old backlight state, send them first
+		err := lcd.flush()
+		if err == nil {
+			err = lcd.writeBus([]byte{lcd.output(lcd.withBacklight(0))})
+		}
+		if err != nil {
+			lg.Warningf("Backlight pulse not restored: %v\n", err)
+		}
+	})
+	return nil
+}
+
+// cancelBacklightPulse keeps a pending pulse from restoring the backlight
+// later, as an explicit backlight setting replaces it.
+func (lcd *Lcd) cancelBacklightPulse() {
+	if lcd.pulseTimer != nil {
+		lcd.pulseTimer.Stop()
+	}
+	lcd.busMutex.Lock()
+	defer lcd.busMutex.Unlock()
+	lcd.pulseGen++
+	lcd.pulsing = false
+}
+
+// FlashBacklight toggles the backlight the specified number of times,
+// holding each state for interval. The backlight ends up in the state
+// it had before the call.
+func (lcd *Lcd) FlashBacklight(times int, interval time.Duration) error {
+	original := lcd.backlightState()
+	for i := 0; i < times; i++ {
+		err := lcd.setBacklight(!original)
+		if err != nil {
+			return err
+		}
+		time.Sleep(interval)
+		err = lcd.setBacklight(original)
+		if err != nil {
+			return err
+		}
+		time.Sleep(interval)
+	}
+	return nil
+}
+
+// Clear blanks the display and moves the cursor home. The cursor and blink
+// settings are sent again afterwards, as some clones reset them.
+func (lcd *Lcd) Clear() error {
+	err := lcd.writeByte(CMD_Clear_Display, 0)
+	if err == nil {
+		err = lcd.Flush()
+	}
+	time.Sleep(2 * time.Millisecond) // Do same delay as Home().
+	if err != nil {
+		return err
+	}
+	// Some clones reset cursor and blink on clear, so send them again
+	return lcd.writeDisplayControl()
+}
+
+// writeDisplayControl sends the display control settings. While asleep
+// the display stays off, Wake turns it on with the settings made since.
+func (lcd *Lcd) writeDisplayControl() error {
+	control := lcd.displayControl
+	if lcd.asleep {
+		control &^= OPT_Enable_Display
+	}
+	err := lcd.writeByte(CMD_Display_Control|control, 0)
+	time.Sleep(2 * time.Millisecond) // Do same delay as Home().
+	return err
+}
+
+// ClearLine blanks the specified line and leaves the cursor at its
+// beginning. Other lines are left untouched.
+func (lcd *Lcd) ClearLine(line int) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	w, _ := lcd.getSize()
+	if w == -1 {
+		return fmt.Errorf("Can't clear line %d of a display with unknown size", line)
+	}
+	err := lcd.writeText(line, 0, strings.Repeat(" ", w))
+	if err != nil {
+		return err
+	}
+	return lcd.SetPosition(line, 0)
+}
+
+// Home moves the cursor to line 0, position 0 and returns a shifted display
+// to its original position. Display content is kept.
+func (lcd *Lcd) Home() error {
+	err := lcd.writeByte(CMD_Return_Home, 0)
+	if err == nil {
+		err = lcd.Flush()
+	}
+	time.Sleep(2 * time.Millisecond) // Page 24 of datasheet says 1.52ms to execute.  We will do slightly longer delay.
+	return err
+}
+
+// ResetToBlank clears the display and homes the cursor, then sends the
+// display control and entry mode settings again in case the controller
+// lost them, e.g. after a glitch on the bus. It's much quicker than the
+// full initialization done by Resume, and keeps the backlight as it is.
+func (lcd *Lcd) ResetToBlank() error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	err := lcd.Clear()
+	if err != nil {
+		return err
+	}
+	err = lcd.Home()
+	if err != nil {
+		return err
+	}
+	// Clear has already sent the display control settings
+	return lcd.writeByte(CMD_Entry_Mode|lcd.displayMode, 0)
+}
+
+// ResetShift undoes any shift made with ScrollDisplayLeft/ScrollDisplayRight
+// without clearing the display. It is the same command as Home, so the
+// cursor also ends up at line 0, position 0.
+func (lcd *Lcd) ResetShift() error {
+	return lcd.Home()
+}
+
+func (lcd *Lcd) DisplayOn() error {
+	lcd.displayControl |= OPT_Enable_Display
+	return lcd.writeDisplayControl()
+}
 
 func (lcd *Lcd) DisplayOff() error {
 	lcd.displayControl = lcd.displayControl &^ OPT_Enable_Display
-	err := lcd.writeByte(CMD_Display_Control|lcd.displayControl, 0)
+	return lcd.writeDisplayControl()
+}
+
+// Sleep blanks the display and turns off the backlight with one command,
+// so the panel goes completely dark. The display content and settings
+// are kept, and Wake brings everything back as it was.
+func (lcd *Lcd) Sleep() error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	// Dimming would keep blinking the backlight, resume it on Wake
+	level := lcd.pwmLevel
+	lcd.stopBacklightPWM()
+	// A backlight pulse ending from now on is kept for Wake
+	lcd.busMutex.Lock()
+	if !lcd.asleep {
+		lcd.wakeBacklight = lcd.backlight
+		lcd.wakeLevel = level
+	}
+	lcd.backlight = false
+	lcd.asleep = true
+	lcd.busMutex.Unlock()
+	err := lcd.writeByte(CMD_Display_Control|lcd.displayControl&^OPT_Enable_Display, 0)
 	time.Sleep(2 * time.Millisecond) // Do same delay as Home().
 	return err
 }
 
-func (lcd *Lcd) BlinkOn() error {
-	lcd.displayControl |= OPT_Enable_Blink
+// Wake undoes Sleep, restoring the display control settings and the
+// backlight state from before, including a level set with
+// SetBacklightLevel.
+func (lcd *Lcd) Wake() error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	if !lcd.asleep {
+		return nil
+	}
+	lcd.busMutex.Lock()
+	lcd.backlight = lcd.wakeBacklight
+	lcd.asleep = false
+	lcd.busMutex.Unlock()
 	err := lcd.writeByte(CMD_Display_Control|lcd.displayControl, 0)
 	time.Sleep(2 * time.Millisecond) // Do same delay as Home().
-	return err
+	if err != nil {
+		return err
+	}
+	if lcd.wakeLevel > 0 {
+		return lcd.SetBacklightLevel(lcd.wakeLevel)
+	}
+	return nil
+}
+
+func (lcd *Lcd) BlinkOn() error {
+	lcd.displayControl |= OPT_Enable_Blink
+	return lcd.writeDisplayControl()
 }
 
 func (lcd *Lcd) BlinkOff() error {
 	lcd.displayControl = lcd.displayControl &^ OPT_Enable_Blink
-	err := lcd.writeByte(CMD_Display_Control|lcd.displayControl, 0)
-	time.Sleep(2 * time.Millisecond) // Do same delay as Home().
-	return err
+	return lcd.writeDisplayControl()
 }
 
 func (lcd *Lcd) CursorOn() error {
 	lcd.displayControl |= OPT_Enable_Cursor
-	err := lcd.writeByte(CMD_Display_Control|lcd.displayControl, 0)
-	time.Sleep(2 * time.Millisecond) // Do same delay as Home().
-	return err
+	return lcd.writeDisplayControl()
 }
 
 func (lcd *Lcd) CursorOff() error {
 	lcd.displayControl = lcd.displayControl &^ OPT_Enable_Cursor
-	err := lcd.writeByte(CMD_Display_Control|lcd.displayControl, 0)
-	time.Sleep(2 * time.Millisecond) // Do same delay as Home().
-	return err
+	return lcd.writeDisplayControl()
 }
 
 func (lcd *Lcd) ScrollDisplayLeft() error {
@@ -405,6 +1398,34 @@ func (lcd *Lcd) ScrollDisplayRight() error {
 	return err
 }
 
+// ScrollDisplay shifts the display contents by positions: negative values
+// scroll left, positive ones right.
+func (lcd *Lcd) ScrollDisplay(positions int) error {
+	for ; positions < 0; positions++ {
+		err := lcd.ScrollDisplayLeft()
+		if err != nil {
+			return err
+		}
+	}
+	for ; positions > 0; positions-- {
+		err := lcd.ScrollDisplayRight()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DisplayShift returns how many positions the display contents are
+// currently scrolled, positive to the right and negative to the left,
+// counted since the last Clear or Home. ScrollDisplay(-DisplayShift())
+// scrolls back without moving the cursor.
+func (lcd *Lcd) DisplayShift() int {
+	return lcd.shift
+}
+
+// LeftRightDisplay makes the cursor move right after each character, the
+// default entry mode.
 func (lcd *Lcd) LeftRightDisplay() error {
 	lcd.displayMode |= OPT_EntryLeft
 	err := lcd.writeByte(CMD_Entry_Mode|lcd.displayMode, 0)
@@ -412,6 +1433,8 @@ func (lcd *Lcd) LeftRightDisplay() error {
 	return err
 }
 
+// RightLeftDisplay makes the cursor move left after each character, so
+// Write and WriteString put text on the display right to left.
 func (lcd *Lcd) RightLeftDisplay() error {
 	lcd.displayMode = lcd.displayMode &^ OPT_EntryLeft
 	err := lcd.writeByte(CMD_Entry_Mode|lcd.displayMode, 0)
@@ -420,11 +1443,38 @@ func (lcd *Lcd) RightLeftDisplay() error {
 }
 
 func (lcd *Lcd) getSize() (width, height int) {
-	switch lcd.lcdType {
+	if lcd.width > 0 {
+		return lcd.width, lcd.height
+	}
+	return lcd.lcdType.size()
+}
+
+// String returns the size of displays of type t, e.g. "16x2", or "unknown".
+func (t LcdType) String() string {
+	w, h := t.size()
+	if w == -1 {
+		if t == LCD_UNKNOWN {
+			return "unknown"
+		}
+		return fmt.Sprintf("LcdType(%d)", int(t))
+	}
+	return fmt.Sprintf("%dx%d", w, h)
+}
+
+// size returns the width and height of displays of type t, or -1, -1 if
+// it's unknown.
+func (t LcdType) size() (width, height int) {
+	switch t {
 	case LCD_16x2:
 		return 16, 2
 	case LCD_20x4:
 		return 20, 4
+	case LCD_16x1:
+		return 16, 1
+	case LCD_40x1:
+		return 40, 1
+	case LCD_40x4:
+		return 40, 4
 	default:
 		return -1, -1
 	}
@@ -432,8 +1482,8 @@ func (lcd *Lcd) getSize() (width, height int) {
 
 func (lcd *Lcd) SetPosition(line, pos int) error {
 	//Not active, so don't try do anything
-	if !lcd.active {
-		return nil
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
 	}
 
 	w, h := lcd.getSize()
@@ -445,14 +1495,89 @@ func (lcd *Lcd) SetPosition(line, pos int) error {
 		return fmt.Errorf("Cursor line %d "+
 			"must be within the range [0..%d]", line, h-1)
 	}
-	lineOffset := []byte{0x00, 0x40, 0x14, 0x54}
-	var b byte = CMD_DDRAM_Set + lineOffset[line] + byte(pos)
+	// With unknown geometry still stay within what the controller can address
+	if h == -1 && (line < 0 || line > 3) {
+		return fmt.Errorf("Cursor line %d "+
+			"must be within the range [0..3] for a display of unknown size", line)
+	}
+	if w == -1 && (pos < 0 || pos > 39) {
+		return fmt.Errorf("Cursor position %d "+
+			"must be within the range [0..39] for a display of unknown size", pos)
+	}
+	lcd.selectController(line)
+	var b byte = CMD_DDRAM_Set + lcd.ddramAddress(line, pos)
 	err := lcd.writeByte(b, 0)
 	return err
 }
 
+// SetDDRAMAddress moves the cursor to the raw DDRAM address addr
+// (0x00..0x7F), for panels whose layout SetPosition doesn't know. On
+// LCD_40x4 it addresses the controller last used.
+func (lcd *Lcd) SetDDRAMAddress(addr byte) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	if addr&CMD_DDRAM_Set != 0 {
+		return fmt.Errorf("DDRAM address 0x%02X "+
+			"must be within the range [0x00..0x7F]", addr)
+	}
+	return lcd.writeByte(CMD_DDRAM_Set|addr, 0)
+}
+
+// skipAddressGap must be called before writing each character of a run
+// along a line. It re-addresses the cursor where
+// a line is not contiguous in DDRAM, which is at the right half of a 16x1
+// display. In right to left entry mode the cursor moves the other way,
+// so then every character is addressed, keeping runs laid out left to right.
+func (lcd *Lcd) skipAddressGap(line, pos int) error {
+	if lcd.lcdType == LCD_16x1 && pos == 8 || lcd.rightToLeft() {
+		return lcd.SetPosition(line, pos)
+	}
+	return nil
+}
+
+// ddramAddress maps a line and position to the controller's DDRAM address.
+func (lcd *Lcd) ddramAddress(line, pos int) byte {
+	if lcd.lineAddress != nil {
+		return lcd.lineAddress(line) + byte(pos)
+	}
+	if lcd.lcdType == LCD_16x1 && pos >= 8 {
+		return 0x40 + byte(pos-8)
+	}
+	if lcd.lcdType == LCD_40x4 {
+		// Each controller holds two of the lines
+		line %= 2
+	}
+	lineOffset := []byte{0x00, 0x40, 0x14, 0x54}
+	return lineOffset[line] + byte(pos)
+}
+
+// writeCharAt writes a single character code at the specified position.
+func (lcd *Lcd) writeCharAt(line, col int, c byte) error {
+	err := lcd.SetPosition(line, col)
+	if err != nil {
+		return err
+	}
+	return lcd.writeByte(c, PIN_RS)
+}
+
+// Write sends buf to the display as character codes from the current
+// cursor position, the cursor moving in the entry mode direction after
+// each. Positioned methods like WriteAt and ShowMessage always lay text
+// out left to right instead.
 func (lcd *Lcd) Write(buf []byte) (int, error) {
 	for i, c := range buf {
+		if lcd.writeNewlines {
+			handled, err := lcd.lineControl(rune(c))
+			if err != nil {
+				return i, err
+			}
+			if handled {
+				continue
+			}
+		}
 		err := lcd.writeByte(c, PIN_RS)
 		if err != nil {
 			return i, err
@@ -466,6 +1591,52 @@ func (lcd *Lcd) Command(cmd byte) error {
 	return err
 }
 
+// WriteRaw sends one byte to the controller as two 4-bit transfers, as
+// data (rs true) or as an instruction (rs false). The cursor position and
+// CGRAM addressing are still tracked, but the display control, entry mode
+// and function set state the getters and helpers rely on is not, so after
+// commands changing those keeping them consistent is up to the caller.
+func (lcd *Lcd) WriteRaw(data byte, rs bool) error {
+	var controlPins byte
+	if rs {
+		controlPins = PIN_RS
+	}
+	return lcd.writeByte(data, controlPins)
+}
+
+// GetDisplayControl returns the OPT_*_Display/Cursor/Blink flags last sent
+// with CMD_Display_Control.
+func (lcd *Lcd) GetDisplayControl() byte {
+	return lcd.displayControl
+}
+
+// GetDisplayMode returns the entry mode flags last sent with CMD_Entry_Mode.
+func (lcd *Lcd) GetDisplayMode() byte {
+	return lcd.displayMode
+}
+
+// GetLcdType returns the display type the display was created with.
+func (lcd *Lcd) GetLcdType() LcdType {
+	return lcd.lcdType
+}
+
+// GoString summarizes the display configuration and state, e.g. for bug
+// reports, and is what fmt's %#v prints.
+func (lcd *Lcd) GoString() string {
+	w, h := lcd.getSize()
+	return fmt.Sprintf("hd44780.Lcd{type: %v, size: %dx%d, active: %t, backlight: %t, "+
+		"writeStrobeDelay: %dµs, resetStrobeDelay: %dµs, settleDelay: %dµs, "+
+		"functionSet: 0x%02X, displayControl: 0x%02X, entryMode: 0x%02X}",
+		lcd.lcdType, w, h, lcd.active.Load(), lcd.backlightState(),
+		lcd.writeStrobeDelay, lcd.resetStrobeDelay, lcd.settleDelay,
+		lcd.displayFunction, lcd.displayControl, lcd.displayMode)
+}
+
+// GetFunctionSet returns the flags last sent with CMD_Function_Set.
+func (lcd *Lcd) GetFunctionSet() byte {
+	return lcd.displayFunction
+}
+
 // GetStrobeDelays returns the WRITE and RESET strobe delays in microseconds.
 func (lcd *Lcd) GetStrobeDelays() (writeDelay, resetDelay uint16) {
 	return lcd.writeStrobeDelay, lcd.resetStrobeDelay
@@ -477,18 +1648,32 @@ func (lcd *Lcd) SetStrobeDelays(writeDelay, resetDelay uint16) {
 	lcd.resetStrobeDelay = resetDelay
 }
 
-// Fill will show the specified character across the entire display
+// GetSettleDelay returns the delay in microseconds between putting data
+// on the bus and raising the strobe.
+func (lcd *Lcd) GetSettleDelay() uint16 {
+	return lcd.settleDelay
+}
+
+// SetSettleDelay sets the delay in microseconds between putting data on
+// the bus and raising the strobe. The default of 50µs is enough for most
+// displays, but some slow clones need longer.
+func (lcd *Lcd) SetSettleDelay(delay uint16) {
+	lcd.settleDelay = delay
+}
+
+// Fill writes char to every cell of the display. It returns an error for
+// a display of unknown size.
 func (lcd *Lcd) Fill(char rune) error {
 	//Not active, so don't try do anything
-	if !lcd.active {
-		return nil
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
 	}
 
 	var width, height = lcd.getSize()
 
-	// Invalid srceen size, do nothing
-	if width*height <= 1 {
-		return nil
+	// Unknown or invalid screen size, there's no area to fill
+	if width < 1 || height < 1 {
+		return fmt.Errorf("Can't fill a display of size %dx%d", width, height)
 	}
 
 	// Fill the display line by line
@@ -501,6 +1686,10 @@ func (lcd *Lcd) Fill(char rune) error {
 
 		// Fill the line
 		for colCount := 0; colCount < width; colCount++ {
+			err = lcd.skipAddressGap(lineCount, colCount)
+			if err != nil {
+				return err
+			}
 			err = lcd.writeByte(byte(char), PIN_RS)
 			if err != nil {
 				return err
@@ -511,9 +1700,140 @@ func (lcd *Lcd) Fill(char rune) error {
 	return nil
 }
 
-// Shutdown will cleanup the LCD display
+// FillRect fills a rectangle of w x h characters, with its top-left corner
+// at the specified line and position, with char. Parts of the rectangle
+// beyond the display edges, including a negative line or position, are cut
+// off.
+func (lcd *Lcd) FillRect(line, col, w, h int, char rune) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	if w < 1 || h < 1 {
+		return fmt.Errorf("Rectangle size %dx%d must be at least 1x1", w, h)
+	}
+	if col < 0 {
+		w, col = w+col, 0
+	}
+	if line < 0 {
+		h, line = h+line, 0
+	}
+	// Entirely left of or above the display
+	if w < 1 || h < 1 {
+		return nil
+	}
+	// Rejects a top-left corner beyond the display
+	err := lcd.SetPosition(line, col)
+	if err != nil {
+		return err
+	}
+	width, height := lcd.getSize()
+	if width != -1 && col+w > width {
+		w = width - col
+	}
+	if height != -1 && line+h > height {
+		h = height - line
+	}
+	for row := line; row < line+h; row++ {
+		err = lcd.SetPosition(row, col)
+		if err != nil {
+			return err
+		}
+		for pos := col; pos < col+w; pos++ {
+			err = lcd.skipAddressGap(row, pos)
+			if err != nil {
+				return err
+			}
+			err = lcd.writeByte(byte(char), PIN_RS)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Repeat writes the character code r count times from the current cursor
+// position, e.g. for borders and bars, stopping at the first failed write.
+// r is sent as is, so glyph codes work; codes above 0xFF return an error,
+// see WriteRune for characters that need translating.
+func (lcd *Lcd) Repeat(r rune, count int) error {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return lcd.inactiveErr()
+	}
+
+	if count < 0 {
+		return fmt.Errorf("Repeat count %d must not be negative", count)
+	}
+	if r < 0 || r > 0xFF {
+		return fmt.Errorf("Character code %U is out of range [0..0xFF]", r)
+	}
+	for i := 0; i < count; i++ {
+		err := lcd.writeByte(byte(r), PIN_RS)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureInitialized returns ErrNotInitialized if lcd wasn't created with
+// one of the constructors, and nil otherwise. Methods check this on their
+// own, so it's only needed to catch the mistake early.
+func (lcd *Lcd) EnsureInitialized() error {
+	if !lcd.initialized {
+		return ErrNotInitialized
+	}
+	return nil
+}
+
+// SetStrictInactive controls what output methods return after Shutdown.
+// By default they silently do nothing and return nil; in strict mode
+// they return ErrInactive instead.
+func (lcd *Lcd) SetStrictInactive(strict bool) {
+	lcd.strictInactive = strict
+}
+
+func (lcd *Lcd) inactiveErr() error {
+	if !lcd.initialized {
+		return ErrNotInitialized
+	}
+	if lcd.strictInactive {
+		return ErrInactive
+	}
+	return nil
+}
+
+// SetActive enables or disables output without touching the display
+// itself. While inactive, output methods do nothing (see SetStrictInactive).
+func (lcd *Lcd) SetActive(active bool) {
+	lcd.active.Store(active)
+}
+
+// IsActive reports whether output to the display is enabled.
+func (lcd *Lcd) IsActive() bool {
+	return lcd.active.Load()
+}
+
+// Resume re-enables a display after Shutdown (or SetActive(false)) and
+// runs the initialization sequence again, so it also recovers a display
+// that was power cycled meanwhile. Display content is cleared, and the
+// backlight stays in its current state.
+func (lcd *Lcd) Resume() error {
+	lcd.active.Store(true)
+	return lcd.initialize()
+}
+
+// Shutdown will cleanup the LCD display. Running animations (Spinner,
+// StartClock etc.) are stopped first.
 func (lcd *Lcd) Shutdown() {
-	lcd.active = false                 //Set active to FALSE.  This will "block" characters being written to display (check functions which check lcd flag)
+	lcd.active.Store(false) //Set active to FALSE.  This will "block" characters being written to display (check functions which check lcd flag)
+	lcd.stopAnimations(animationStopTimeout)
+	if lcd.pulseTimer != nil {
+		lcd.pulseTimer.Stop()
+	}
 	time.Sleep(250 * time.Millisecond) //Sleep to allow for any instructions/commands to complete before we continue
 
 	// Shutdown display
@@ -0,0 +1,299 @@
+package hd44780
+
+import (
+	"sync"
+	"testing"
+)
+
+// recordingBus stands in for the I2C connection and keeps everything
+// written to it. Reads return 0xFF, like a backpack with RW grounded.
+type recordingBus struct {
+	mutex  sync.Mutex
+	writes int
+	data   []byte
+}
+
+func (b *recordingBus) WriteBytes(buf []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.writes++
+	b.data = append(b.data, buf...)
+	return len(buf), nil
+}
+
+func (b *recordingBus) ReadBytes(buf []byte) (int, error) {
+	for i := range buf {
+		buf[i] = 0xFF
+	}
+	return len(buf), nil
+}
+
+// writeCount returns the number of I2C write transactions so far.
+func (b *recordingBus) writeCount() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.writes
+}
+
+// sentByte is a byte the controller received, as data or instruction.
+type sentByte struct {
+	data byte
+	rs   bool
+}
+
+// sent decodes the bytes the controller latched from the PCF8574 layout
+// of the bus traffic since reset: every strobe (EN high) carries a nibble,
+// high nibble first.
+func (b *recordingBus) sent() []sentByte {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	var out []sentByte
+	var high byte
+	half := false
+	for _, v := range b.data {
+		if v&PIN_EN == 0 {
+			continue
+		}
+		if !half {
+			high = v & 0xF0
+		} else {
+			out = append(out, sentByte{high | v>>4, v&PIN_RS != 0})
+		}
+		half = !half
+	}
+	return out
+}
+
+// reset forgets the traffic recorded so far.
+func (b *recordingBus) reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.writes = 0
+	b.data = nil
+}
+
+// newTestLcd creates a display on a recording bus.
+func newTestLcd(tb testing.TB, opts ...Option) (*Lcd, *recordingBus) {
+	bus := &recordingBus{}
+	opts = append([]Option{WithoutPowerOnDelay()}, opts...)
+	lcd, err := newLcdOnBus(bus, opts...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	bus.reset()
+	return lcd, bus
+}
+
+func BenchmarkFill(b *testing.B) {
+	for _, bm := range []struct {
+		name     string
+		buffered bool
+	}{{"Unbuffered", false}, {"Buffered", true}} {
+		b.Run(bm.name, func(b *testing.B) {
+			lcd, bus := newTestLcd(b, WithType(LCD_20x4))
+			err := lcd.SetBufferedWrites(bm.buffered)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err = lcd.Fill('#')
+				if err == nil {
+					err = lcd.Flush()
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(bus.writeCount())/float64(b.N), "buswrites/op")
+		})
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	lcd16x2, _ := newTestLcd(t, WithType(LCD_16x2))
+	lcd1x2, _ := newTestLcd(t, WithGeometry(1, 2))
+	const (
+		lines    = ShowOptions(SHOW_LINE_1 | SHOW_LINE_2)
+		wrap     = ShowOptions(SHOW_WORD_WRAP)
+		ellipsis = ShowOptions(SHOW_ELIPSE_IF_NOT_FIT)
+		newlines = ShowOptions(SHOW_NEWLINES)
+		clear    = ShowOptions(SHOW_CLEAR_LINES)
+	)
+	blank := "                "
+	for _, c := range []struct {
+		name    string
+		lcd     *Lcd
+		text    string
+		options ShowOptions
+		want    []string
+	}{
+		{"wrap", lcd16x2, "Hello World this is", lines | wrap,
+			[]string{"Hello World this", "is"}},
+		{"wrap ellipsis", lcd16x2, "Hello World this is wonderfully long", lines | wrap | ellipsis,
+			[]string{"Hello World this", "is wonderfully~"}},
+		{"wrap ellipsis drops cut word", lcd16x2, "0123456789abcdef aaaaaaa bbbbbbbb more", lines | wrap | ellipsis,
+			[]string{"0123456789abcdef", "aaaaaaa~"}},
+		{"ellipsis", lcd16x2, "abcdefghijklmnopqrstuvwxyz0123456789", lines | ellipsis,
+			[]string{"abcdefghijklmnop", "qrstuvwxyz01234~"}},
+		{"long word wrap", lcd16x2, "abcdefghijklmnopqrstuvwxyz0123456789", lines | wrap,
+			[]string{"abcdefghijklmnop", "qrstuvwxyz012345"}},
+		{"long word wrap ellipsis", lcd16x2, "abcdefghijklmnopqrstuvwxyz0123456789", lines | wrap | ellipsis,
+			[]string{"abcdefghijklmnop", "qrstuvwxyz01234~"}},
+		{"width 1", lcd1x2, "ab", lines,
+			[]string{"a", "b"}},
+		{"width 1 ellipsis", lcd1x2, "abc", lines | ellipsis,
+			[]string{"a", "~"}},
+		{"width 1 wrap ellipsis", lcd1x2, "a bc", lines | wrap | ellipsis,
+			[]string{"a", "~"}},
+		{"newline kept without flag", lcd16x2, "a\nb", lines,
+			[]string{"a\nb"}},
+		{"newlines", lcd16x2, "Line1\nLine2\nLine3", lines | newlines | ellipsis,
+			[]string{"Line1", "Line2~"}},
+		{"newlines wrap ellipsis", lcd16x2, "Line1\nLine2 is a bit longer\nLine3", lines | newlines | wrap | ellipsis,
+			[]string{"Line1", "Line2 is a bit~"}},
+		{"clear lines", lcd16x2, "x", lines | clear,
+			[]string{"x" + blank[1:], blank}},
+		{"newlines clear lines", lcd16x2, "abc\n", lines | newlines | clear,
+			[]string{"abc" + blank[3:], blank}},
+		{"newlines clear lines right", lcd16x2, "one\ntwo", lines | newlines | clear | SHOW_ALIGN_RIGHT,
+			[]string{blank[3:] + "one", blank[3:] + "two"}},
+	} {
+		got := c.lcd.splitText(c.text, c.options)
+		if len(got) != len(c.want) {
+			t.Errorf("%s: splitText(%q) = %q, want %q", c.name, c.text, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: splitText(%q) = %q, want %q", c.name, c.text, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestClearResendsDisplayControl(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		sleep  bool
+		expect byte
+	}{
+		{"awake", false, OPT_Enable_Display | OPT_Enable_Cursor | OPT_Enable_Blink},
+		{"asleep", true, OPT_Enable_Cursor | OPT_Enable_Blink},
+	} {
+		lcd, bus := newTestLcd(t, WithType(LCD_16x2))
+		var err error
+		for _, f := range []func() error{lcd.CursorOn, lcd.BlinkOn} {
+			if err == nil {
+				err = f()
+			}
+		}
+		if err == nil && c.sleep {
+			err = lcd.Sleep()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		bus.reset()
+		err = lcd.Clear()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []sentByte{
+			{CMD_Clear_Display, false},
+			{CMD_Display_Control | c.expect, false},
+		}
+		got := bus.sent()
+		if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("%s: Clear sent %v, want %v", c.name, got, want)
+		}
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	lcd, bus := newTestLcd(t, WithType(LCD_16x2))
+	err := lcd.Repeat('€', 3)
+	if err == nil {
+		t.Error("Repeat of a code above 0xFF succeeded")
+	}
+	if n := bus.writeCount(); n != 0 {
+		t.Errorf("Rejected Repeat made %d bus writes, want none", n)
+	}
+	err = lcd.Repeat(0x05, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sentByte{0x05, true}
+	got := bus.sent()
+	if len(got) != 3 || got[0] != want || got[1] != want || got[2] != want {
+		t.Errorf("Repeat sent %v, want 3 times %v", got, want)
+	}
+}
+
+// shortWriteBus delivers only the first half of the first bulk write.
+type shortWriteBus struct {
+	recordingBus
+	cut bool
+}
+
+func (b *shortWriteBus) WriteBytes(buf []byte) (int, error) {
+	if !b.cut && len(buf) > 1 {
+		b.cut = true
+		return b.recordingBus.WriteBytes(buf[:len(buf)/2])
+	}
+	return b.recordingBus.WriteBytes(buf)
+}
+
+func TestWriteRetryAfterShortWrite(t *testing.T) {
+	bus := &shortWriteBus{}
+	lcd, err := newLcdOnBus(bus, WithType(LCD_16x2), WithoutPowerOnDelay())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lcd.SetWriteRetries(1, 0)
+	err = lcd.SetBufferedWrites(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus.reset()
+	bus.cut = false
+	err = lcd.WriteAt(0, 0, "abcd")
+	if err == nil {
+		err = lcd.Flush()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []sentByte{{CMD_DDRAM_Set, false},
+		{'a', true}, {'b', true}, {'c', true}, {'d', true}}
+	got := bus.sent()
+	if len(got) != len(want) {
+		t.Fatalf("Retried transfer sent %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("Retried transfer sent %v, want %v", got, want)
+		}
+	}
+}
+
+func TestDisplayControlWhileAsleep(t *testing.T) {
+	lcd, bus := newTestLcd(t, WithType(LCD_16x2))
+	err := lcd.Sleep()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []func() error{lcd.BlinkOn, lcd.CursorOn, lcd.DisplayOn} {
+		bus.reset()
+		err = f()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range bus.sent() {
+			if s.data&0xF8 == CMD_Display_Control && s.data&OPT_Enable_Display != 0 {
+				t.Errorf("Display control 0x%02X turns the display on while asleep", s.data)
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package hd44780
+
+import "errors"
+
+// Menu is a scrollable list of items filling the display, with the
+// selected item marked in the first column.
+type Menu struct {
+	lcd      *Lcd
+	items    []string
+	selected int
+	top      int
+	marker   rune
+}
+
+// NewMenu creates a menu of items with the first item selected.
+func NewMenu(lcd *Lcd, items []string) *Menu {
+	return &Menu{lcd: lcd, items: items, marker: '>'}
+}
+
+// SetMarker changes the character marking the selected item from the
+// default '>'. Pass rune(index) to use a custom glyph from CGRAM.
+func (m *Menu) SetMarker(marker rune) {
+	m.marker = marker
+}
+
+// Selected returns the index of the selected item.
+func (m *Menu) Selected() int {
+	return m.selected
+}
+
+// MoveUp selects the previous item, if there is one.
+func (m *Menu) MoveUp() {
+	if m.selected > 0 {
+		m.selected--
+	}
+}
+
+// MoveDown selects the next item, if there is one.
+func (m *Menu) MoveDown() {
+	if m.selected < len(m.items)-1 {
+		m.selected++
+	}
+}
+
+// Render draws as many items as there are display lines, scrolling the
+// list so the selected item is visible. Lines are padded to the full
+// width, so nothing of a previous render remains.
+func (m *Menu) Render() error {
+	w, h := m.lcd.getSize()
+	if w == -1 {
+		return errors.New("Can't show a menu on a display with unknown size")
+	}
+	if m.selected < m.top {
+		m.top = m.selected
+	} else if m.selected >= m.top+h {
+		m.top = m.selected - h + 1
+	}
+	for line := 0; line < h; line++ {
+		index := m.top + line
+		text := ""
+		if index < len(m.items) {
+			marker := ' '
+			if index == m.selected {
+				marker = m.marker
+			}
+			text = string(marker) + m.items[index]
+		}
+		err := m.lcd.WriteAt(line, 0, fitWidth(text, w))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package hd44780
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/d2r2/go-i2c"
+)
+
+// Option configures a display created with NewLcdWithOptions.
+type Option func(lcd *Lcd) error
+
+// WithType sets the display type, which determines its size. Without it
+// (or WithGeometry) the size is unknown.
+func WithType(lcdType LcdType) Option {
+	return func(lcd *Lcd) error {
+		lcd.lcdType = lcdType
+		return nil
+	}
+}
+
+// WithGeometry sets the size of displays not covered by the LcdType
+// constants, e.g. 20x2 or 8x2. Displays with more than two lines can be
+// at most 20 characters wide, as the 3rd and 4th lines continue the
+// 1st and 2nd in DDRAM.
+func WithGeometry(width, height int) Option {
+	return func(lcd *Lcd) error {
+		maxWidth := 40
+		if height > 2 {
+			maxWidth = 20
+		}
+		if height < 1 || height > 4 || width < 1 || width > maxWidth {
+			return fmt.Errorf("Display size %dx%d isn't supported, "+
+				"must be up to 40x2 or 20x4", width, height)
+		}
+		lcd.width, lcd.height = width, height
+		return nil
+	}
+}
+
+// WithLineAddressing sets the DDRAM address each line starts at, for
+// displays laid out differently from the usual 0x00, 0x40, 0x14, 0x54,
+// e.g. 16x4 panels with lines at 0x00, 0x40, 0x10, 0x50:
+//
+//	WithLineAddressing(func(line int) byte {
+//		return []byte{0x00, 0x40, 0x10, 0x50}[line]
+//	})
+//
+// It's only called for lines on the display, and replaces all other
+// addressing, so it doesn't suit LCD_16x1 or LCD_40x4.
+func WithLineAddressing(address func(line int) byte) Option {
+	return func(lcd *Lcd) error {
+		if address == nil {
+			return errors.New("Line addressing function is nil")
+		}
+		lcd.lineAddress = address
+		return nil
+	}
+}
+
+// WithPins sets how the port expander is wired to the display, see
+// PinMap. The default is PINS_PCF8574.
+func WithPins(pins PinMap) Option {
+	return func(lcd *Lcd) error {
+		lcd.pins = pins
+		return nil
+	}
+}
+
+// WithCharROM selects the character ROM fitted to the display, which
+// WriteMapped translates text for. The default is ROM_A00.
+func WithCharROM(rom CharROM) Option {
+	return func(lcd *Lcd) error {
+		if rom != ROM_A00 && rom != ROM_A02 {
+			return fmt.Errorf("Unknown character ROM %d", rom)
+		}
+		lcd.charROM = rom
+		return nil
+	}
+}
+
+// WithBacklightPolarity works like SetBacklightPolarity, so the backlight
+// is already off during initialization on active low backpacks.
+func WithBacklightPolarity(activeHigh bool) Option {
+	return func(lcd *Lcd) error {
+		lcd.SetBacklightPolarity(activeHigh)
+		return nil
+	}
+}
+
+// WithStrobeDelays works like SetStrobeDelays, for displays that need
+// other timing already during initialization.
+func WithStrobeDelays(writeDelay, resetDelay uint16) Option {
+	return func(lcd *Lcd) error {
+		lcd.SetStrobeDelays(writeDelay, resetDelay)
+		return nil
+	}
+}
+
+// WithNibbleSwap works like SetNibbleSwap, already for initialization.
+func WithNibbleSwap() Option {
+	return func(lcd *Lcd) error {
+		lcd.SetNibbleSwap(true)
+		return nil
+	}
+}
+
+// WithoutPowerOnDelay skips the 100ms wait for the display to power up at
+// the start of initialization, e.g. to reattach quickly to a display that
+// is already running. It's only safe if the display has had power for
+// more than 40ms.
+func WithoutPowerOnDelay() Option {
+	return func(lcd *Lcd) error {
+		lcd.skipPowerOnDelay = true
+		return nil
+	}
+}
+
+// WithoutInitialClear skips clearing the display and homing the cursor at
+// the end of initialization, also in Resume, so text put on the display
+// before a soft re-init stays visible. The cursor is then wherever the
+// controller left it, so position it before writing.
+func WithoutInitialClear() Option {
+	return func(lcd *Lcd) error {
+		lcd.keepContent = true
+		return nil
+	}
+}
+
+// WithInitRetries verifies that the display took the initialization, and
+// repeats it up to retries times if not, for panels that sometimes miss
+// it on a cold start. Verification writes a pattern to CGRAM and reads it
+// back, which needs the RW line wired (see PinMap.RW). Otherwise, also
+// when RW turns out to be grounded, the function set is just sent once
+// more, and only a pattern read back wrong counts as a failed attempt.
+func WithInitRetries(retries int) Option {
+	return func(lcd *Lcd) error {
+		if retries < 0 {
+			return fmt.Errorf("Init retries %d must not be negative", retries)
+		}
+		lcd.initRetries = retries
+		return nil
+	}
+}
+
+// WithContrastDevice works like SetContrastDevice.
+func WithContrastDevice(bus *i2c.I2C, register byte) Option {
+	return func(lcd *Lcd) error {
+		lcd.SetContrastDevice(bus, register)
+		return nil
+	}
+}
+
+// NewLcdWithOptions creates and initializes a display configured by opts,
+// e.g. NewLcdWithOptions(bus, WithType(LCD_16x2), WithPins(PINS_MCP23008)).
+// Without options it drives a display of unknown size through a PCF8574
+// backpack.
+func NewLcdWithOptions(i2c *i2c.I2C, opts ...Option) (*Lcd, error) {
+	if i2c == nil {
+		return nil, errors.New("I2C connection is nil")
+	}
+	return newLcdOnBus(i2c, opts...)
+}
+
+// newLcdOnBus does the work of NewLcdWithOptions on any bus.
+func newLcdOnBus(bus i2cBus, opts ...Option) (*Lcd, error) {
+	this := &Lcd{i2c: bus,
+		initialized:      true,
+		pins:             PINS_PCF8574,
+		backlight:        false,
+		backlightInvert:  false,
+		lcdType:          LCD_UNKNOWN,
+		writeStrobeDelay: defaultWriteStrobeDelay,
+		resetStrobeDelay: defaultResetStrobeDelay,
+		settleDelay:      defaultSettleDelay,
+		backlightPulse:   defaultBacklightPulse,
+		strictInactive:   false,
+		writeRetries:     0,
+		retryBackoff:     0,
+		buffered:         false,
+		tabWidth:         4,
+		displayFunction:  0x00,
+		displayControl:   0x00,
+		displayMode:      0x00,
+	}
+	this.active.Store(true)
+	for _, opt := range opts {
+		err := opt(this)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if this.lcdType == LCD_40x4 && this.pins.EN2 == 0 {
+		return nil, errors.New("LCD_40x4 needs the EN2 pin " +
+			"of its second controller set in the PinMap, e.g. PINS_PCF8574_40x4")
+	}
+	if this.pins.ContrastRegister != 0 && this.contrastBus == nil {
+		this.contrastBus = bus
+		this.contrastRegister = this.pins.ContrastRegister
+	}
+	for _, buf := range this.pins.Init {
+		_, err := writeAll(bus, buf)
+		if err != nil {
+			return nil, fmt.Errorf("Port expander setup failed: %w", err)
+		}
+	}
+
+	err := this.initialize()
+	if err != nil {
+		return nil, err
+	}
+	return this, nil
+}
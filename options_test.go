@@ -0,0 +1,36 @@
+package hd44780
+
+import (
+	"strings"
+	"testing"
+)
+
+// zeroReadBus is a display that answers every read with 0x00: never
+// busy, but CGRAM reads back empty.
+type zeroReadBus struct {
+	recordingBus
+}
+
+func (b *zeroReadBus) ReadBytes(buf []byte) (int, error) {
+	for i := range buf {
+		buf[i] = 0
+	}
+	return len(buf), nil
+}
+
+func TestWithInitRetries(t *testing.T) {
+	_, err := newLcdOnBus(&zeroReadBus{}, WithType(LCD_16x2),
+		WithInitRetries(2), WithoutPowerOnDelay())
+	if err == nil || !strings.Contains(err.Error(), "after 3 initialization attempts") {
+		t.Errorf("Wrong read back returned %v, want an error after 3 attempts", err)
+	}
+	// The recording bus reads 0xFF like grounded RW, the layouts without
+	// RW can't read at all: nothing to verify with either
+	for _, pins := range []PinMap{PINS_PCF8574, PINS_PCF8574_40x4, PINS_MCP23008} {
+		_, err = newLcdOnBus(&recordingBus{}, WithType(LCD_16x2),
+			WithPins(pins), WithInitRetries(2), WithoutPowerOnDelay())
+		if err != nil {
+			t.Errorf("Initialization without reads returned %v", err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package hd44780
+
+import (
+	"math/bits"
+
+	"github.com/d2r2/go-i2c"
+)
+
+// PinMap describes how the outputs of the I2C port expander are wired to
+// the display. Every pin is given as a bit mask of the expander's output
+// byte, or 0 if the line isn't connected to the expander.
+type PinMap struct {
+	RS, RW, EN, Backlight byte
+	D4, D5, D6, D7        byte
+	// EN2 is the enable of the second controller of LCD_40x4 displays,
+	// which EN then only drives the first controller of.
+	EN2 byte
+	// Register is the expander register the output byte is written to,
+	// or 0 for expanders like the PCF8574 that take plain byte writes.
+	Register byte
+	// ContrastRegister is the register of a digital potentiometer on the
+	// expander's address that sets the contrast, or 0 if there is none.
+	ContrastRegister byte
+	// Init lists raw I2C writes sent once before the display is
+	// initialized, e.g. to configure the expander's pins as outputs.
+	Init [][]byte
+}
+
+// PINS_PCF8574 is the wiring of the common PCF8574 based backpacks:
+// D4-D7 on the high nibble, then backlight, EN, RW and RS.
+var PINS_PCF8574 = PinMap{
+	RS: PIN_RS, RW: PIN_RW, EN: PIN_EN, Backlight: PIN_BACKLIGHT,
+	D4: 0x10, D5: 0x20, D6: 0x40, D7: 0x80,
+}
+
+// PINS_PCF8574_40x4 is the PCF8574 wiring for LCD_40x4 displays, with the
+// expander's RW output driving the second enable instead. RW itself is
+// grounded, so the display can't be read.
+var PINS_PCF8574_40x4 = PinMap{
+	RS: PIN_RS, EN: PIN_EN, EN2: PIN_RW, Backlight: PIN_BACKLIGHT,
+	D4: 0x10, D5: 0x20, D6: 0x40, D7: 0x80,
+}
+
+// PINS_MCP23008 is the wiring of MCP23008 based backpacks (e.g. Adafruit's):
+// RS on GP1, EN on GP2, D4-D7 on GP3-GP6, backlight on GP7 and RW grounded.
+var PINS_MCP23008 = PinMap{
+	RS: 0x02, EN: 0x04, Backlight: 0x80,
+	D4: 0x08, D5: 0x10, D6: 0x20, D7: 0x40,
+	Register: 0x09, // GPIO
+	Init: [][]byte{
+		{0x05, 0x20}, // IOCON: no address increment, so bytes stream into GPIO
+		{0x00, 0x00}, // IODIR: all pins are outputs
+	},
+}
+
+// PINS_MCP23017 is the MCP23008 backpack wiring on port A of an MCP23017.
+var PINS_MCP23017 = PinMap{
+	RS: 0x02, EN: 0x04, Backlight: 0x80,
+	D4: 0x08, D5: 0x10, D6: 0x20, D7: 0x40,
+	Register: 0x12, // GPIOA
+	Init: [][]byte{
+		{0x0A, 0x20}, // IOCON: no address increment, so bytes stream into GPIOA
+		{0x00, 0x00}, // IODIRA: all pins are outputs
+	},
+}
+
+// dataPins returns the masks of D4..D7, in bit order of the data nibble.
+func (p *PinMap) dataPins() [4]byte {
+	return [4]byte{p.D4, p.D5, p.D6, p.D7}
+}
+
+// output maps a byte in the package's internal layout (the PCF8574 one:
+// data nibble in the high bits, PIN_* constants below) to the expander's.
+func (p *PinMap) output(data byte) byte {
+	var b byte
+	for _, pin := range [][2]byte{{PIN_RS, p.RS}, {PIN_RW, p.RW},
+		{PIN_EN, p.EN}, {PIN_BACKLIGHT, p.Backlight}} {
+		if data&pin[0] != 0 {
+			b |= pin[1]
+		}
+	}
+	for i, mask := range p.dataPins() {
+		if data&(0x10<<uint(i)) != 0 {
+			b |= mask
+		}
+	}
+	return b
+}
+
+// selectController makes the controller holding line the one addressed
+// by following character writes and reads. Only LCD_40x4 has two.
+func (lcd *Lcd) selectController(line int) {
+	if lcd.lcdType == LCD_40x4 {
+		lcd.controller = line / 2
+	}
+}
+
+// enableMask returns the enable pins to strobe for a byte. With two
+// controllers characters, cursor addressing and reads go to the selected
+// one, while all other instructions and CGRAM data go to both, so they
+// stay configured alike and show the same glyphs.
+func (lcd *Lcd) enableMask(data, controlPins byte) byte {
+	if lcd.pins.EN2 == 0 {
+		return lcd.pins.EN
+	}
+	if controlPins&PIN_RS != 0 && !lcd.cgramMode ||
+		controlPins&PIN_RS == 0 && data&CMD_DDRAM_Set != 0 {
+		return lcd.selectedEnable()
+	}
+	return lcd.pins.EN | lcd.pins.EN2
+}
+
+// selectedEnable returns the enable pin of the selected controller.
+func (lcd *Lcd) selectedEnable() byte {
+	if lcd.controller == 1 {
+		return lcd.pins.EN2
+	}
+	return lcd.pins.EN
+}
+
+// output translates data to the expander's pins like PinMap.output, but
+// raises the enable pins picked by enableMask for PIN_EN.
+func (lcd *Lcd) output(data byte) byte {
+	b := lcd.pins.output(data &^ PIN_EN)
+	if data&PIN_EN != 0 {
+		b |= lcd.enable
+	}
+	return b
+}
+
+// inputNibble extracts the data nibble from a byte read from the expander.
+func (p *PinMap) inputNibble(b byte) byte {
+	var nibble byte
+	for i, mask := range p.dataPins() {
+		if b&mask != 0 {
+			nibble |= 1 << uint(i)
+		}
+	}
+	return nibble
+}
+
+// reverseNibbles reverses the bit order within both nibbles of b.
+func reverseNibbles(b byte) byte {
+	r := bits.Reverse8(b)
+	return r<<4 | r>>4
+}
+
+// SetNibbleSwap makes all transfers reverse the order of the data lines,
+// D4 with D7 and D5 with D6, for backpacks wired the wrong way round that
+// otherwise only show garbage. It's off by default. As initialization
+// has to be sent swapped too, use WithNibbleSwap or call Resume after
+// enabling it. A PinMap with the data pins swapped has the same effect.
+func (lcd *Lcd) SetNibbleSwap(enabled bool) {
+	lcd.nibbleSwap = enabled
+}
+
+// NewLcdWithPins works like NewLcd, for backpacks wired differently from
+// the PCF8574 default, e.g. NewLcdWithPins(bus, LCD_16x2, PINS_MCP23008).
+func NewLcdWithPins(i2c *i2c.I2C, lcdType LcdType, pins PinMap) (*Lcd, error) {
+	return NewLcdWithOptions(i2c, WithType(lcdType), WithPins(pins))
+}
@@ -0,0 +1,157 @@
+package hd44780
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrReadUnsupported is returned by methods that read from the controller
+// when it doesn't answer, as on backpacks with the RW line tied to ground
+// where the data lines always read back high, or when the PinMap has no
+// RW pin or uses a register based expander.
+var ErrReadUnsupported = errors.New("Display doesn't answer reads, RW may be grounded")
+
+// busyTimeout is how long waitBusy polls the busy flag. The slowest
+// instructions (Clear and Home) take 1.52ms according to the datasheet.
+const busyTimeout = 10 * time.Millisecond
+
+// readByte reads one byte from the controller as two 4-bit transfers:
+// data at the address counter (rs true), or the busy flag and address
+// counter (rs false).
+func (lcd *Lcd) readByte(rs bool) (byte, error) {
+	// Reading through register based expanders would need the pins
+	// switched to inputs first, which isn't supported
+	if lcd.pins.RW == 0 || lcd.pins.Register != 0 {
+		return 0, ErrReadUnsupported
+	}
+	lcd.busMutex.Lock()
+	defer lcd.busMutex.Unlock()
+	// Pending writes have to go out before the bus changes direction
+	err := lcd.flush()
+	if err != nil {
+		return 0, err
+	}
+	// Data lines are set high, so the controller can pull them low
+	pins := lcd.withBacklight(0xF0 | PIN_RW)
+	if rs {
+		pins |= PIN_RS
+	}
+	lcd.enable = lcd.selectedEnable()
+	strobe := lcd.output(pins | PIN_EN)
+	pins = lcd.output(pins)
+	var value byte
+	buf := make([]byte, 1)
+	for i := 0; i < 2; i++ {
+		err = lcd.writeBus([]byte{strobe})
+		if err != nil {
+			return 0, err
+		}
+		time.Sleep(time.Duration(lcd.writeStrobeDelay) * time.Microsecond)
+		n, err := lcd.i2c.ReadBytes(buf)
+		if err != nil {
+			return 0, fmt.Errorf("I2C read failed: %w", err)
+		}
+		if n < len(buf) {
+			return 0, errors.New("Short I2C read: no byte received")
+		}
+		value = value<<4 | lcd.pins.inputNibble(buf[0])
+		err = lcd.writeBus([]byte{pins})
+		if err != nil {
+			return 0, err
+		}
+		time.Sleep(time.Duration(lcd.resetStrobeDelay) * time.Microsecond)
+	}
+	if lcd.nibbleSwap {
+		value = reverseNibbles(value)
+	}
+	return value, nil
+}
+
+// waitBusy polls the busy flag until the controller is ready for the next
+// instruction. ErrReadUnsupported is returned if it never gets ready.
+func (lcd *Lcd) waitBusy() error {
+	deadline := time.Now().Add(busyTimeout)
+	for {
+		status, err := lcd.readByte(false)
+		if err != nil {
+			return err
+		}
+		if status&0x80 == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrReadUnsupported
+		}
+	}
+}
+
+// ReadDDRAM reads back n characters shown from the specified line and
+// position on, e.g. to verify what's actually on screen. It needs the RW
+// line wired to the expander, otherwise ErrReadUnsupported is returned.
+// The cursor is left where it was.
+func (lcd *Lcd) ReadDDRAM(line, col, n int) ([]byte, error) {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return nil, lcd.inactiveErr()
+	}
+
+	err := lcd.checkRect(line, col, n, 1)
+	if err != nil {
+		return nil, err
+	}
+	// With RW grounded every read returns 0xFF, which looks like busy
+	err = lcd.waitBusy()
+	if err != nil {
+		return nil, err
+	}
+	cursorLine, cursorPos := lcd.cursorLine, lcd.cursorPos
+	err = lcd.SetPosition(line, col)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, n)
+	for i := range data {
+		err = lcd.skipAddressGap(line, col+i)
+		if err != nil {
+			return nil, err
+		}
+		data[i], err = lcd.readByte(true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, lcd.restoreCursor(cursorLine, cursorPos)
+}
+
+// ReadGlyph reads back the dot pattern stored in CGRAM slot index (0..7).
+// Like ReadDDRAM it needs the RW line wired and leaves the cursor alone.
+func (lcd *Lcd) ReadGlyph(index int) ([8]byte, error) {
+	var pattern [8]byte
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return pattern, lcd.inactiveErr()
+	}
+
+	err := checkGlyphIndex(index)
+	if err != nil {
+		return pattern, err
+	}
+	err = lcd.waitBusy()
+	if err != nil {
+		return pattern, err
+	}
+	cursorLine, cursorPos := lcd.cursorLine, lcd.cursorPos
+	err = lcd.writeByte(CMD_CGRAM_Set|byte(index<<3), 0)
+	if err != nil {
+		return pattern, err
+	}
+	for row := range pattern {
+		b, err := lcd.readByte(true)
+		if err != nil {
+			return pattern, err
+		}
+		pattern[row] = b & 0x1F
+	}
+	return pattern, lcd.restoreCursor(cursorLine, cursorPos)
+}
@@ -0,0 +1,46 @@
+package hd44780
+
+import "errors"
+
+// ScrollLog uses the display as a rolling log. Appended lines fill the
+// display from the top, and once it's full every new line is shown on the
+// bottom line and pushes the older lines up.
+type ScrollLog struct {
+	lcd   *Lcd
+	lines []string
+}
+
+// NewScrollLog creates an empty log drawn on all lines of lcd.
+func NewScrollLog(lcd *Lcd) *ScrollLog {
+	return &ScrollLog{lcd: lcd}
+}
+
+// Append adds line at the bottom of the log and redraws the display.
+// Lines longer than the display width are truncated.
+func (sl *ScrollLog) Append(line string) error {
+	_, h := sl.lcd.getSize()
+	if h == -1 {
+		return errors.New("Can't scroll a display with unknown size")
+	}
+	sl.lines = append(sl.lines, line)
+	if len(sl.lines) > h {
+		// Drop lines scrolled off the top
+		sl.lines = append(sl.lines[:0], sl.lines[len(sl.lines)-h:]...)
+	}
+	for i, text := range sl.lines {
+		err := sl.lcd.ClearLine(i)
+		if err != nil {
+			return err
+		}
+		err = sl.lcd.WriteAt(i, 0, text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lines returns the lines currently shown, oldest first.
+func (sl *ScrollLog) Lines() []string {
+	return append([]string(nil), sl.lines...)
+}
@@ -0,0 +1,240 @@
+package hd44780
+
+import "errors"
+
+// trackCursor follows the cursor position through every byte sent by
+// writeByte, so that writes of all kinds keep it up to date. The position
+// can run past the end of a line, just like the controller's address
+// counter does.
+func (lcd *Lcd) trackCursor(data byte, controlPins byte) {
+	if controlPins&PIN_RS != 0 {
+		if lcd.cgramMode {
+			return
+		}
+		if lcd.displayMode&OPT_EntryLeft != 0 {
+			lcd.cursorPos++
+		} else {
+			lcd.cursorPos--
+		}
+		if lcd.displayMode&OPT_Increment != 0 {
+			// Entry shift moves the display against the writing direction
+			if lcd.displayMode&OPT_EntryLeft != 0 {
+				lcd.shift--
+			} else {
+				lcd.shift++
+			}
+		}
+		return
+	}
+	switch {
+	case data&CMD_DDRAM_Set != 0:
+		lcd.cgramMode = false
+		lcd.cursorLine, lcd.cursorPos = lcd.addressPosition(data &^ CMD_DDRAM_Set)
+	case data&CMD_CGRAM_Set != 0:
+		lcd.cgramMode = true
+	case data == CMD_Clear_Display, data&^0x01 == CMD_Return_Home:
+		lcd.cgramMode = false
+		lcd.cursorLine, lcd.cursorPos = 0, 0
+		lcd.shift = 0
+		lcd.controller = 0
+	case data&0xF0 == CMD_Cursor_Shift && data&OPT_Display_Move != 0:
+		if data&OPT_Move_Right != 0 {
+			lcd.shift++
+		} else {
+			lcd.shift--
+		}
+	case data&0xF0 == CMD_Cursor_Shift && data&OPT_Display_Move == 0:
+		if data&OPT_Move_Right != 0 {
+			lcd.cursorPos++
+		} else {
+			lcd.cursorPos--
+		}
+	}
+}
+
+// addressPosition is the reverse of ddramAddress: it finds the line whose
+// DDRAM range contains addr, and the position of addr on that line.
+func (lcd *Lcd) addressPosition(addr byte) (line, pos int) {
+	if lcd.lineAddress != nil {
+		// Lines start at arbitrary addresses, pick the closest start
+		_, h := lcd.getSize()
+		if h == -1 {
+			h = 4
+		}
+		line = -1
+		for i := 0; i < h; i++ {
+			start := lcd.lineAddress(i)
+			if start <= addr && (line == -1 || start > lcd.lineAddress(line)) {
+				line = i
+			}
+		}
+		if line == -1 {
+			return 0, int(addr)
+		}
+		return line, int(addr - lcd.lineAddress(line))
+	}
+	if lcd.lcdType == LCD_16x1 {
+		if addr >= 0x40 {
+			return 0, 8 + int(addr-0x40)
+		}
+		return 0, int(addr)
+	}
+	if lcd.lcdType == LCD_40x4 {
+		line = 2 * lcd.controller
+		if addr >= 0x40 {
+			return line + 1, int(addr - 0x40)
+		}
+		return line, int(addr)
+	}
+	_, h := lcd.getSize()
+	if h == -1 || h > 4 {
+		h = 4
+	}
+	line = -1
+	var lineStart byte
+	for i := 0; i < h; i++ {
+		offset := lcd.ddramAddress(i, 0)
+		// Lines 0/2 and 1/3 share the lower and upper half of DDRAM
+		sameHalf := (offset >= 0x40) == (addr >= 0x40)
+		if sameHalf && offset <= addr && (line == -1 || offset > lineStart) {
+			line, lineStart = i, offset
+		}
+	}
+	if line == -1 {
+		return 0, int(addr)
+	}
+	return line, int(addr - lineStart)
+}
+
+// restoreCursor moves the cursor back to a position saved from the
+// tracked cursorLine and cursorPos, e.g. after a CGRAM access.
+func (lcd *Lcd) restoreCursor(line, pos int) error {
+	if pos < 0 {
+		pos = 0
+	}
+	lcd.selectController(line)
+	addr := lcd.ddramAddress(line, pos) &^ CMD_DDRAM_Set
+	return lcd.writeByte(CMD_DDRAM_Set|addr, 0)
+}
+
+// SetTabWidth sets the distance between tab stops used by WriteString.
+// Values below 1 are treated as 1. The default is 4.
+func (lcd *Lcd) SetTabWidth(n int) {
+	if n < 1 {
+		n = 1
+	}
+	lcd.tabWidth = n
+}
+
+// SetWriteNewlines makes Write treat '\n' and '\r' like WriteString does,
+// instead of sending them to the display as character codes. Off by
+// default, since codes 8..15 show the CGRAM glyphs like 0..7.
+func (lcd *Lcd) SetWriteNewlines(enabled bool) {
+	lcd.writeNewlines = enabled
+}
+
+// lineControl handles the line control characters of WriteString: '\n'
+// moves the cursor to the start of the next line (from the last line back
+// to the first) and '\r' to the start of the current one. In right to left
+// entry mode a line starts at its end. It reports whether c was one of
+// them.
+func (lcd *Lcd) lineControl(c rune) (bool, error) {
+	switch c {
+	case '\n':
+		w, h := lcd.getSize()
+		if h == -1 {
+			h = 4
+		}
+		return true, lcd.SetPosition((lcd.cursorLine+1)%h, lcd.lineStart(w))
+	case '\r':
+		w, _ := lcd.getSize()
+		return true, lcd.SetPosition(lcd.cursorLine, lcd.lineStart(w))
+	}
+	return false, nil
+}
+
+// WriteString writes s from the current cursor position, treating the
+// display as a small terminal: text wraps onto the next line at the end
+// of a line (and from the last line back to the first), '\n' starts the
+// next line, '\r' returns to the start of the line and a tab moves to the
+// next tab stop (see SetTabWidth) without going past the end of the line.
+// In right to left entry mode (RightLeftDisplay) lines are filled from
+// their end instead. It returns the number of bytes of s that were written.
+func (lcd *Lcd) WriteString(s string) (int, error) {
+	//Not active, so don't try do anything
+	if !lcd.active.Load() {
+		return 0, lcd.inactiveErr()
+	}
+
+	w, h := lcd.getSize()
+	if w == -1 {
+		return 0, errors.New("Can't wrap text on a display with unknown size")
+	}
+	for i, c := range s {
+		if handled, err := lcd.lineControl(c); handled {
+			if err != nil {
+				return i, err
+			}
+			continue
+		}
+		if c == '\t' {
+			for n := lcd.tabSpaces(w); n > 0; n-- {
+				err := lcd.writeWrapped(' ', w, h)
+				if err != nil {
+					return i, err
+				}
+			}
+			continue
+		}
+		err := lcd.writeWrapped(byte(c), w, h)
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(s), nil
+}
+
+// rightToLeft reports whether the entry mode moves the cursor to the left
+// after each character (see RightLeftDisplay).
+func (lcd *Lcd) rightToLeft() bool {
+	return lcd.displayMode&OPT_EntryLeft == 0
+}
+
+// lineStart returns the position text starts at in the entry direction:
+// the first one, or the last one when writing right to left.
+func (lcd *Lcd) lineStart(width int) int {
+	if lcd.rightToLeft() && width != -1 {
+		return width - 1
+	}
+	return 0
+}
+
+// tabSpaces returns how many spaces move the cursor to the next tab stop
+// in the entry direction, without going past the end of the line.
+func (lcd *Lcd) tabSpaces(width int) int {
+	pos := lcd.cursorPos
+	if lcd.rightToLeft() {
+		return pos%lcd.tabWidth + 1
+	}
+	stop := (pos/lcd.tabWidth + 1) * lcd.tabWidth
+	if stop > width {
+		stop = width
+	}
+	return stop - pos
+}
+
+// writeWrapped writes one character, first moving to the beginning
+// of the next line if the cursor has run past the end of its line.
+func (lcd *Lcd) writeWrapped(c byte, width, height int) error {
+	if lcd.cursorPos < 0 || lcd.cursorPos >= width {
+		err := lcd.SetPosition((lcd.cursorLine+1)%height, lcd.lineStart(width))
+		if err != nil {
+			return err
+		}
+	}
+	err := lcd.skipAddressGap(lcd.cursorLine, lcd.cursorPos)
+	if err != nil {
+		return err
+	}
+	return lcd.writeByte(c, PIN_RS)
+}